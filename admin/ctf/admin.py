@@ -1,5 +1,7 @@
 from django.contrib import admin
-from .models import Announcement, Audit, Task, TaskFlags, Team
+from django.db.models import F
+from django.utils import timezone
+from .models import Announcement, Audit, Task, TaskFlags, Team, TeamModeration, TeamSession
 
 
 @admin.register(Announcement)
@@ -22,6 +24,38 @@ class TaskFlagsAdmin(admin.ModelAdmin):
     list_display = ('id', 'task', 'flag')
 
 
+def revoke_sessions(modeladmin, request, queryset):
+    # bumping version logs out also sessions created before team_session table
+    TeamSession.objects.filter(team__in=queryset, revoked_at__isnull=True).update(revoked_at=timezone.now())
+    queryset.update(session_version=F('session_version') + 1)
+
+
+revoke_sessions.short_description = 'Revoke all sessions of selected teams'
+
+
 @admin.register(Team)
 class TeamAdmin(admin.ModelAdmin):
-    list_display = ('id', 'name', 'email', 'active', 'country', 'created_at')
+    list_display = ('id', 'name', 'email', 'active', 'state', 'country', 'created_at')
+    list_filter = ('state',)
+    actions = [revoke_sessions]
+
+    def save_model(self, request, obj, form, change):
+        super().save_model(request, obj, form, change)
+        # same as admin api, every state change is logged with acting admin
+        if 'state' in form.changed_data or 'state_reason' in form.changed_data:
+            TeamModeration.objects.create(team=obj, admin=request.user.get_username(), state=obj.state, reason=obj.state_reason)
+            if obj.state == 'banned':
+                revoke_sessions(self, request, Team.objects.filter(pk=obj.pk))
+
+
+@admin.register(TeamModeration)
+class TeamModerationAdmin(admin.ModelAdmin):
+    list_display = ('id', 'team', 'admin', 'state', 'reason', 'created_at')
+    list_filter = ('state',)
+    readonly_fields = ('team', 'admin', 'state', 'reason', 'created_at')
+
+
+@admin.register(TeamSession)
+class TeamSessionAdmin(admin.ModelAdmin):
+    list_display = ('id', 'team', 'user_ip', 'user_agent', 'created_at', 'last_seen_at', 'revoked_at')
+    list_filter = ('revoked_at',)
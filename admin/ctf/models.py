@@ -1,4 +1,7 @@
 from django.db import models
+from django.db.models import F
+from django.db.models.signals import post_delete, post_save
+from django.dispatch import receiver
 
 
 class Announcement(models.Model):
@@ -15,6 +18,7 @@ class Announcement(models.Model):
 
 
 class Task(models.Model):
+    slug = models.CharField(max_length=64, null=True, default=None, blank=True, unique=True)
     name = models.CharField(max_length=255, null=False)
     description = models.TextField(null=False)
     category = models.CharField(max_length=128, null=False)
@@ -40,16 +44,27 @@ class TaskFlags(models.Model):
 
 
 class Team(models.Model):
+    STATE_CHOICES = (
+        ('active', 'Active'),
+        ('hidden', 'Hidden'),
+        ('banned', 'Banned'),
+        ('disqualified', 'Disqualified'),
+    )
+
     name = models.CharField(max_length=255, unique=True, null=False, blank=False)
     email = models.CharField(max_length=255, unique=True, null=False, blank=False)
     password = models.CharField(max_length=255, null=False)
     active = models.BooleanField(default=False)
+    state = models.CharField(max_length=16, choices=STATE_CHOICES, default='active', null=False)
+    state_reason = models.CharField(max_length=255, null=False, blank=True)
     created_at = models.DateTimeField(auto_now_add=True, null=False)
 
     country = models.CharField(max_length=4, null=False, blank=True)
     avatar = models.CharField(max_length=64, null=False, blank=True)
     affiliation = models.CharField(max_length=64, null=False, blank=True)
     website = models.CharField(max_length=255, null=False, blank=True)
+    session_version = models.IntegerField(default=0, null=False)
+    deleted_at = models.DateTimeField(null=True, default=None, blank=True)
 
     def __str__(self):
         return f'{self.name} (#{self.id})'
@@ -59,11 +74,40 @@ class Team(models.Model):
         managed = False
 
 
+class TeamSession(models.Model):
+    team = models.ForeignKey('Team', on_delete=models.DO_NOTHING, null=False, related_name='+')
+    user_ip = models.CharField(max_length=64, null=False, blank=True)
+    user_agent = models.CharField(max_length=255, null=False, blank=True)
+    created_at = models.DateTimeField(auto_now_add=True, null=False)
+    last_seen_at = models.DateTimeField(auto_now_add=True, null=False)
+    revoked_at = models.DateTimeField(null=True, default=None, blank=True)
+
+    class Meta:
+        db_table = 'team_session'
+        managed = False
+
+
+class TeamModeration(models.Model):
+    team = models.ForeignKey('Team', on_delete=models.DO_NOTHING, null=False, related_name='+')
+    admin = models.CharField(max_length=64, null=False)
+    state = models.CharField(max_length=16, choices=Team.STATE_CHOICES, null=False)
+    reason = models.CharField(max_length=255, null=False, blank=True)
+    created_at = models.DateTimeField(auto_now_add=True, null=False)
+
+    class Meta:
+        db_table = 'team_moderation'
+        managed = False
+
+
 class TeamAvatar(models.Model):
-    team_id = models.ForeignKey('Team', unique=True, on_delete=models.CASCADE, null=False, related_name='+')
+    team = models.ForeignKey('Team', unique=True, on_delete=models.CASCADE, null=False, related_name='+')
     avatar_path = models.CharField(max_length=64, null=False)
     avatar = models.BinaryField(null=False)
 
+    class Meta:
+        db_table = 'team_avatar'
+        managed = False
+
 
 class Audit(models.Model):
     task = models.ForeignKey('Task', on_delete=models.DO_NOTHING, null=False, related_name='+')
@@ -74,3 +118,19 @@ class Audit(models.Model):
         db_table = 'audit'
         unique_together = (('task', 'team'),)
         managed = False
+
+
+class CacheVersion(models.Model):
+    name = models.CharField(max_length=64, primary_key=True)
+    version = models.BigIntegerField(null=False, default=0)
+
+    class Meta:
+        db_table = 'cache_version'
+        managed = False
+
+
+@receiver([post_save, post_delete], sender=Task)
+@receiver([post_save, post_delete], sender=TaskFlags)
+def bump_flags_version(sender, **kwargs):
+    # web servers reload flags cache when version changes
+    CacheVersion.objects.filter(name='flags').update(version=F('version') + 1)
@@ -1,80 +1,7 @@
-create table announcement
-(
-	id int auto_increment,
-	title varchar(255) not null,
-	description text not null,
-    created_at timestamp default CURRENT_TIMESTAMP not null,
-	constraint announcement_pk
-		primary key (id)
-);
---
-create table task
-(
-	id int auto_increment,
-	name varchar(255) not null,
-	description text not null,
-	category varchar(128) not null,
-	difficult varchar(32) not null,
-	started_at timestamp default null null,
-    created_at timestamp default CURRENT_TIMESTAMP not null,
-	constraint task_pk
-		primary key (id)
-);
---
-create table task_flags
-(
-	id int auto_increment,
-	task_id int not null,
-	flag varchar(255) not null unique,
-	constraint task_flags_pk
-		primary key (id),
-	constraint task_flags_task_id_fk
-		foreign key (task_id) references task (id)
-);
---
-create table team
-(
-	id int auto_increment,
-	name varchar(255) not null unique,
-	email varchar(255) not null unique,
-	password varchar(255) not null,
-	active boolean default false not null,
-    created_at timestamp default CURRENT_TIMESTAMP not null,
-    avatar varchar(64) not null,
-    country varchar(4) not null,
-    affiliation varchar(64) not null default '',
-    website varchar(255) not null default '',
-	constraint team_pk
-		primary key (id)
-);
---
-create table team_avatar (
-	id int auto_increment,
-	team_id int not null unique,
-    avatar_path varchar(64) not null,
-    avatar MEDIUMBLOB not null,
-	constraint team_avatar_pk
-		primary key (id),
-	constraint team_avatar_team_id_fk
-		foreign key (team_id) references team (id)
-);
---
-create table audit
-(
-	id int auto_increment,
-	team_id int not null,
-	task_id int not null,
-	created_at timestamp default CURRENT_TIMESTAMP not null,
-	constraint audit_pk
-		primary key (id),
-	constraint audit_team_id_fk
-		foreign key (team_id) references team (id),
-	constraint audit_task_id_fk
-		foreign key (task_id) references task (id)
-);
 --
-create unique index audit_task_id_team_id_uindex
-	on audit (task_id, team_id);
+-- app tables are created by web service migrations (web/db/migrations_mysql.go,
+-- migrations_postgres.go and migrations_sqlite.go for other databases),
+-- run on start (MIGRATE_ON_START) or with "ctfctl migrate up"
 --
 --
 -- django sql
@@ -0,0 +1,113 @@
+import * as React from "react";
+
+// api of recaptcha, hcaptcha and turnstile scripts, they share explicit render
+interface ICaptchaApi {
+    render(container: HTMLElement, params: object): string;
+    execute(widget: string | HTMLElement): void;
+    reset(widget: string): void;
+}
+
+// script url (onload callback name is appended) and global object it creates
+const providers: {[provider: string]: [string, string]} = {
+    recaptcha: ["https://www.google.com/recaptcha/api.js?render=explicit&onload=", "grecaptcha"],
+    hcaptcha: ["https://js.hcaptcha.com/1/api.js?render=explicit&onload=", "hcaptcha"],
+    turnstile: ["https://challenges.cloudflare.com/turnstile/v0/api.js?render=explicit&onload=", "turnstile"],
+};
+
+const scripts: {[provider: string]: Promise<ICaptchaApi>} = {};
+
+function loadScript(provider: string): Promise<ICaptchaApi> {
+    if( !scripts[provider] ) {
+        const [url, global] = providers[provider];
+        const callback = "onCaptchaLoad_" + provider;
+
+        scripts[provider] = new Promise((resolve, reject) => {
+            (window as any)[callback] = () => resolve((window as any)[global]);
+
+            const script = document.createElement("script");
+            script.src = url + callback;
+            script.async = true;
+            script.defer = true;
+            script.onerror = () => {
+                delete scripts[provider];
+                reject(new Error("captcha script failed to load"));
+            };
+            document.head.appendChild(script);
+        });
+    }
+    return scripts[provider];
+}
+
+// Captcha renders widget of provider served in /api/v1/info, token is passed to onVerify after execute.
+// For "test" and "disabled" providers (or when info is not known) nothing is rendered and token is empty.
+export default class Captcha extends React.Component<ICaptchaProps, {}> {
+    private refContainer = React.createRef<HTMLDivElement>();
+    private widget: Promise<[ICaptchaApi, string]> | null = null;
+
+    componentDidMount(): void {
+        const {provider, siteKey} = this.props;
+        const container = this.refContainer.current;
+        if( !providers[provider] || !container )
+            return;
+
+        this.widget = loadScript(provider).then(api => {
+            const params: any = {
+                sitekey: siteKey,
+                theme: "dark",
+                callback: this.verify,
+            };
+            if( provider === "turnstile" ) {
+                // turnstile has no invisible size, it shows up only when interaction is needed
+                params.execution = "execute";
+                params.appearance = "interaction-only";
+            } else {
+                params.size = "invisible";
+            }
+            return [api, api.render(container, params)] as [ICaptchaApi, string];
+        });
+        this.widget.catch(this.error);
+    }
+
+    componentWillUnmount(): void {
+        this.widget = null;
+    }
+
+    render( ) {
+        return <div className={"captcha"} ref={this.refContainer} />;
+    }
+
+    execute = ( ) => {
+        if( !this.widget ) {
+            this.props.onVerify("");
+            return;
+        }
+
+        this.widget.then(([api, widgetID]) => {
+            if( this.props.provider === "turnstile" && this.refContainer.current )
+                api.execute(this.refContainer.current);
+            else
+                api.execute(widgetID);
+        }).catch(this.error);
+    };
+
+    private verify = (token: string) => {
+        if( !this.widget )
+            return;
+
+        // token is single use, next submit needs new one
+        this.widget.then(([api, widgetID]) => api.reset(widgetID));
+        this.props.onVerify(token);
+    };
+
+    private error = (e: Error) => {
+        console.error("captcha", e);
+        this.props.onError("Captcha could not be loaded. Try again.");
+    };
+}
+
+interface ICaptchaProps {
+    provider: string;
+    siteKey: string;
+    onVerify: (token: string) => void;
+    onError: (message: string) => void;
+}
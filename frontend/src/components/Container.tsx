@@ -9,6 +9,7 @@ import {
     LoginPage,
     NotFoundPage,
     RegisterPage,
+    ResetPasswordPage,
     RulesPage,
     ScoreboardPage,
     SettingsPage,
@@ -35,6 +36,7 @@ const Container: React.FunctionComponent<IContainerProps> = ( { routing}: IConta
         "/settings": SettingsPage,
         "/login": LoginPage,
         "/register": RegisterPage,
+        "/reset-password": ResetPasswordPage,
         "/404": NotFoundPage,
     };
 
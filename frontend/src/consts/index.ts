@@ -6,4 +6,3 @@ let _avatarUrl = "";
 
 export const baseUrl = _baseUrl;
 export const avatarUrl = _avatarUrl;
-export const recaptchaToken = "6LeRCcQUAAAAAB2p9yYIK52LvYLLkSP-Sn8ByZcD";
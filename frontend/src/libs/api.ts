@@ -16,8 +16,10 @@ export enum ErrorCodes {
     invalid_password_or_username = "invalid_password_or_username",
     internal_error = "internal_error",
     email_or_name_already_exists = "email_or_name_already_exists",
+    reserved_team_name = "reserved_team_name",
     not_authorize = "not_authorize",
     already_solved = "already_solved",
+    invalid_token = "invalid_token",
 
     undefined_error = "undefined_error",
 }
@@ -26,7 +28,7 @@ export namespace ErrorCodes {
     export function toHumanMessage(code: ErrorCodes): string {
         const a: any = {
             [ErrorCodes.invalid_json]: "Invalid payload. I you get this error contact with admins!",
-            [ErrorCodes.invalid_avatar]: "Avatar should have max. 200kb and max. 256px width or height.",
+            [ErrorCodes.invalid_avatar]: "Avatar should be a PNG, JPEG or WebP image of max. 2MB.",
             [ErrorCodes.invalid_email]: "Email is invalid.",
             [ErrorCodes.invalid_country]: "Country code is invalid.",
             [ErrorCodes.invalid_website]: "URL must start with \"https://\"",
@@ -37,8 +39,10 @@ export namespace ErrorCodes {
             [ErrorCodes.invalid_password_or_username]: "Team not exists or invalid password",
             [ErrorCodes.internal_error]: "Internal error. I you get this error recently contact with admins!",
             [ErrorCodes.email_or_name_already_exists]: "Team name or email already exists.",
+            [ErrorCodes.reserved_team_name]: "Team names starting with \"deleted-\" and @deleted.invalid emails are reserved.",
             [ErrorCodes.not_authorize]: "Not authorize. Please login :)",
             [ErrorCodes.already_solved]: "You already solved this challenge.",
+            [ErrorCodes.invalid_token]: "The link is invalid or has expired. Request a new one.",
             [ErrorCodes.invalid_team_name_ascii]: "Invalid team name. Should contains only ascii characters!",
             [ErrorCodes.invalid_team_name_length]: "Invalid team name. Should have at least 1 character!",
             [ErrorCodes.undefined_error]: "Unknown error. Try again.",
@@ -76,6 +80,8 @@ interface IInfoResponse {
     teams_count: number;
     countries_count: number;
     tasks_unsolved_count: number;
+    captcha_provider: string;
+    captcha_site_key: string;
 }
 
 export interface ITaskAuditResponse {
@@ -379,6 +385,27 @@ export async function SetLogin(input: ILoginRequest): Promise<[ITeamResponse | n
     return [json, null];
 }
 
+export interface IResetPasswordRequest {
+    token: string;
+    password: string;
+}
+
+export async function SetResetPassword(input: IResetPasswordRequest): Promise<ErrorCodes | null> {
+    const resp = await http.post({
+        url: baseUrl + "/team/password/reset",
+        data: input,
+    });
+
+    if (resp.status !== 200) {
+        let out = (await resp.text()) as ErrorCodes;
+        if(!Object.values(ErrorCodes).includes(out)) {
+            out = ErrorCodes.undefined_error;
+        }
+        return out;
+    }
+    return null;
+}
+
 export async function SetLogout(): Promise<ErrorCodes | null> {
     const resp = await http.post({
         url: baseUrl + "/team/logout",
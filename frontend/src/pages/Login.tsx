@@ -2,12 +2,11 @@ import * as React from "react";
 import {inject, observer} from "mobx-react";
 import {observable} from "mobx";
 import {RouterStore} from "mobx-react-router";
-import * as Recaptcha from "react-recaptcha";
 
+import Captcha from "@components/Captcha";
 import Footer from "@components/Footer";
 
 import {IRootStore} from "@store/index";
-import {recaptchaToken} from "@consts/index";
 
 import {ErrorCodes, ILoginRequest, SetLogin} from "@libs/api";
 
@@ -20,10 +19,16 @@ export class LoginPage extends React.Component<ILoginPageProps, {}> {
     private refEmailAddress = React.createRef<HTMLInputElement>();
     private refPassword = React.createRef<HTMLInputElement>();
     private refSubmit = React.createRef<HTMLButtonElement>();
-    private recaptchaInstance: Recaptcha | null = null;
+    private captchaInstance: Captcha | null = null;
 
     @observable errorMessage: string = "";
 
+    componentDidMount(): void {
+        // captcha provider and site key are in info
+        if( this.props.store.ctf.infoState === "none" )
+            this.props.store.ctf.fetchInfo( );
+    }
+
     render( ) {
         return (
             <div className={"page login"}>
@@ -45,15 +50,13 @@ export class LoginPage extends React.Component<ILoginPageProps, {}> {
 
                         <button className={"submitButton"} ref={this.refSubmit} type={"submit"}>log in</button>
 
-                        <Recaptcha
-                            ref={e => this.recaptchaInstance = e}
-                            sitekey={recaptchaToken}
-                            size="invisible"
-                            render={"explicit"}
-                            onloadCallback={() => null}
-                            verifyCallback={this.verifyCaptcha}
-                            theme={"dark"}
-                        />
+                        {this.captchaLoaded() && <Captcha
+                            ref={e => this.captchaInstance = e}
+                            provider={this.props.store.ctf.info.captcha_provider}
+                            siteKey={this.props.store.ctf.info.captcha_site_key}
+                            onVerify={this.verifyCaptcha}
+                            onError={this.captchaError}
+                        />}
                     </form>
 
                     {/*<a href={"/forgot"} title={"Reset password"} className={"reset"} onClick={this.onClick}>Reset password</a>*/}
@@ -68,7 +71,7 @@ export class LoginPage extends React.Component<ILoginPageProps, {}> {
     private formSubmit = (e: React.FormEvent<HTMLFormElement>) => {
         e.preventDefault();
 
-        this.recaptchaInstance && this.recaptchaInstance.execute();
+        this.captchaInstance && this.captchaInstance.execute();
     };
 
     private onClick = (e: React.MouseEvent<HTMLAnchorElement, MouseEvent>) => {
@@ -80,6 +83,16 @@ export class LoginPage extends React.Component<ILoginPageProps, {}> {
             this.props.routing.push(href.value);
     };
 
+    private captchaLoaded = ( ) => {
+        const state = this.props.store.ctf.infoState;
+        // on error captcha is rendered anyway, server tells if token was required
+        return state === "done" || state === "error";
+    };
+
+    private captchaError = (message: string) => {
+        this.errorMessage = message;
+    };
+
     private verifyCaptcha = (captchaToken: string) => {
         const form: ILoginRequest = {
             email: (this.refEmailAddress.current && this.refEmailAddress.current.value) || '',
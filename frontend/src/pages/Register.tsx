@@ -2,13 +2,12 @@ import * as React from "react";
 import {inject, observer} from "mobx-react";
 import {observable} from "mobx";
 import {RouterStore} from "mobx-react-router";
-import * as Recaptcha from "react-recaptcha";
 
+import Captcha from "@components/Captcha";
 import Footer from "@components/Footer";
 
 import {IRootStore} from "@store/index";
 import {Countries} from "@consts/country";
-import {recaptchaToken} from "@consts/index";
 
 import {ErrorCodes, IRegisterRequest, SetRegister} from "@libs/api";
 
@@ -21,11 +20,17 @@ export class RegisterPage extends React.Component<IRegisterPageProps, {}> {
     private refPassword = React.createRef<HTMLInputElement>();
     private refCountry = React.createRef<HTMLSelectElement>();
     private refSubmit = React.createRef<HTMLButtonElement>();
-    private recaptchaInstance: Recaptcha | null = null;
+    private captchaInstance: Captcha | null = null;
 
     @observable errorMessage: string = "";
     @observable successMessage: string = "";
 
+    componentDidMount(): void {
+        // captcha provider and site key are in info
+        if( this.props.store.ctf.infoState === "none" )
+            this.props.store.ctf.fetchInfo( );
+    }
+
     render( ) {
         return (
             <div className={"page register"}>
@@ -73,15 +78,13 @@ export class RegisterPage extends React.Component<IRegisterPageProps, {}> {
 
                         <button className={"submitButton"} ref={this.refSubmit} type={"submit"}>register</button>
 
-                        <Recaptcha
-                            ref={e => this.recaptchaInstance = e}
-                            sitekey={recaptchaToken}
-                            size="invisible"
-                            render={"explicit"}
-                            onloadCallback={() => null}
-                            verifyCallback={this.verifyCaptcha}
-                            theme={"dark"}
-                        />
+                        {this.captchaLoaded() && <Captcha
+                            ref={e => this.captchaInstance = e}
+                            provider={this.props.store.ctf.info.captcha_provider}
+                            siteKey={this.props.store.ctf.info.captcha_site_key}
+                            onVerify={this.verifyCaptcha}
+                            onError={this.captchaError}
+                        />}
                     </form>
 
                     <Footer sticky={true} />
@@ -93,7 +96,17 @@ export class RegisterPage extends React.Component<IRegisterPageProps, {}> {
     private formSubmit = ( e: React.FormEvent<HTMLFormElement> ) => {
         e.preventDefault();
 
-        this.recaptchaInstance && this.recaptchaInstance.execute();
+        this.captchaInstance && this.captchaInstance.execute();
+    };
+
+    private captchaLoaded = ( ) => {
+        const state = this.props.store.ctf.infoState;
+        // on error captcha is rendered anyway, server tells if token was required
+        return state === "done" || state === "error";
+    };
+
+    private captchaError = (message: string) => {
+        this.errorMessage = message;
     };
 
     private verifyCaptcha = (captchaToken: string) => {
@@ -0,0 +1,109 @@
+import * as React from "react";
+import {inject, observer} from "mobx-react";
+import {observable} from "mobx";
+import {RouterStore} from "mobx-react-router";
+
+import Footer from "@components/Footer";
+
+import {ErrorCodes, IResetPasswordRequest, SetResetPassword} from "@libs/api";
+
+import "@styles/login.scss";
+
+
+// opened from link in password reset email, token is in query string
+@inject("routing")
+@observer
+export class ResetPasswordPage extends React.Component<IResetPasswordPageProps, {}> {
+    private refPassword = React.createRef<HTMLInputElement>();
+    private refRepeatPassword = React.createRef<HTMLInputElement>();
+    private refSubmit = React.createRef<HTMLButtonElement>();
+
+    @observable errorMessage: string = "";
+    @observable successMessage: string = "";
+
+    render( ) {
+        return (
+            <div className={"page reset-password"}>
+                <div className={"inner"}>
+                    <h1 className={"mainTitle"}>Reset password</h1>
+
+                    {this.errorMessage && this.errorMessage.length && <div className={"errorMessage"}>{this.errorMessage}</div>}
+                    {this.successMessage && this.successMessage.length && <div className={"successMessage"}>{this.successMessage}</div>}
+
+                    <form onSubmit={this.formSubmit}>
+                        <div className={"form-group"}>
+                            <label htmlFor={"password"}>new password</label>
+                            <input type={"password"} name={"password"} placeholder={"NEW PASSWORD"} id={"password"} ref={this.refPassword} />
+                        </div>
+
+                        <div className={"form-group"}>
+                            <label htmlFor={"repeatPassword"}>repeat password</label>
+                            <input type={"password"} name={"repeatPassword"} placeholder={"REPEAT PASSWORD"} id={"repeatPassword"} ref={this.refRepeatPassword} />
+                        </div>
+
+                        <button className={"submitButton"} ref={this.refSubmit} type={"submit"}>set password</button>
+                    </form>
+
+                    <a href={"/login"} title={"Log in"} className={"register"} onClick={this.onClick}>Back to <span>log in</span></a>
+
+                    <Footer sticky={true} />
+                </div>
+            </div>
+        )
+    }
+
+    private formSubmit = (e: React.FormEvent<HTMLFormElement>) => {
+        e.preventDefault();
+
+        const password = (this.refPassword.current && this.refPassword.current.value) || '';
+        const repeatPassword = (this.refRepeatPassword.current && this.refRepeatPassword.current.value) || '';
+        if(password !== repeatPassword) {
+            this.successMessage = "";
+            this.errorMessage = "Passwords do not match.";
+            return;
+        }
+
+        const form: IResetPasswordRequest = {
+            token: new URLSearchParams(this.props.routing.location.search).get("token") || '',
+            password: password,
+        };
+
+        this.refSubmit.current && this.refSubmit.current.setAttribute("disabled", "disabled");
+
+        (async () => {
+            let err = null;
+            try {
+                const err2 = await SetResetPassword(form);
+                if(err2) {
+                    err = ErrorCodes.toHumanMessage(err2);
+                }
+            } catch (e) {
+                err = String(e);
+            }
+            if(err !== null) {
+                this.errorMessage = String(err);
+                this.successMessage = "";
+
+                return;
+            }
+
+            this.errorMessage = "";
+            this.successMessage = "Password changed, you can log in now.";
+        })().finally(() => {
+            this.refSubmit.current && this.refSubmit.current.removeAttribute("disabled");
+        });
+    };
+
+    private onClick = (e: React.MouseEvent<HTMLAnchorElement, MouseEvent>) => {
+        e.preventDefault();
+
+        const href = e.currentTarget.attributes.getNamedItem("href");
+
+        if( !!href && !!this.props.routing )
+            this.props.routing.push(href.value);
+    };
+}
+
+interface IResetPasswordPageProps {
+    routing: RouterStore
+}
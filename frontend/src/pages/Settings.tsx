@@ -38,6 +38,8 @@ export class SettingsPage extends React.Component<ISettingsPageProps, {}> {
         if(!this.props.store.ctf.myTeam)
            return null;
 
+        const avatarModeration = this.props.store.ctf.myTeam.api.avatar_moderation;
+
         return (
             <div className={"page settings"}>
                 <div className={"inner"}>
@@ -45,6 +47,8 @@ export class SettingsPage extends React.Component<ISettingsPageProps, {}> {
 
                     {this.errorMessage && this.errorMessage.length && <div className={"errorMessage"}>{this.errorMessage}</div>}
                     {this.successMessage && this.successMessage.length && <div className={"successMessage"}>{this.successMessage}</div>}
+                    {avatarModeration && avatarModeration.state === "pending" && <div className={"successMessage"}>New avatar is waiting for review.</div>}
+                    {avatarModeration && avatarModeration.state === "rejected" && <div className={"errorMessage"}>Avatar rejected: {avatarModeration.reason}</div>}
 
                     <form onSubmit={this.formSubmit}>
                         <div className={"form-group avatar"}>
@@ -141,7 +145,11 @@ export class SettingsPage extends React.Component<ISettingsPageProps, {}> {
             this.errorMessage = "";
             this.successMessage = "Settings updated!";
 
-            if(this.refFile.current) this.refFile.current.value = "";
+            if(this.refFile.current && this.refFile.current.value) {
+                this.refFile.current.value = "";
+                // avatar could be held for review
+                this.props.store.ctf.fetchMyTeam();
+            }
         })().finally(() => {
             this.refSubmit.current && this.refSubmit.current.removeAttribute("disabled");
         });
@@ -4,6 +4,7 @@ export * from "./Home";
 export * from "./Login";
 export * from "./NotFound";
 export * from "./Register";
+export * from "./ResetPassword";
 export * from "./Rules";
 export * from "./Scoreboard";
 export * from "./Settings";
@@ -118,6 +118,10 @@ export const MyTeam = types
                 created_at: types.optional(DateFromString, nullDate),
             })), []),
             created_at: types.optional(DateFromString, nullDate),
+            avatar_moderation: types.optional(types.maybeNull(types.model({
+                state: types.optional(types.string, ""),
+                reason: types.optional(types.string, ""),
+            })), null),
         }),
     })
     .actions((self) => ({
@@ -169,6 +173,8 @@ export const CtfStore = types
             teams_count: types.optional(types.number, 0),
             countries_count: types.optional(types.number, 0),
             tasks_unsolved_count: types.optional(types.number, 0),
+            captcha_provider: types.optional(types.string, ""),
+            captcha_site_key: types.optional(types.string, ""),
         }), {}),
 
         tasksState: types.optional(types.enumeration("State", ["none", "pending", "done", "error"]), "none"),
@@ -258,7 +264,7 @@ export const CtfStore = types
                 const [info, err] = yield GetInfo();
                 if(err !== null || info === null) {
                     console.error("Failed to fetch", err);
-                    self.teamsState = "error";
+                    self.infoState = "error";
                     return
                 }
 
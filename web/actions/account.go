@@ -0,0 +1,186 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/models"
+	"ctfplatform/storage"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ReservedTeamName = errors.New("reserved team name")
+
+// CheckTeamName rejects name and email which deleted team gets, registered "deleted-<id>" team would make
+// deletion of team <id> fail. Compared case insensitive as unique index of mysql does.
+func CheckTeamName(name string, email string) error {
+	if strings.HasPrefix(strings.ToLower(name), models.DeletedTeamNamePrefix) || strings.HasSuffix(strings.ToLower(email), models.DeletedTeamEmailSuffix) {
+		return ReservedTeamName
+	}
+	return nil
+}
+
+type TeamExportProfile struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Active      bool       `json:"active"`
+	State       string     `json:"state"`
+	Country     string     `json:"country"`
+	Affiliation string     `json:"affiliation"`
+	Website     string     `json:"website"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
+
+type TeamExportSession struct {
+	ID         int       `json:"id"`
+	UserIP     string    `json:"user_ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Revoked    bool      `json:"revoked"`
+}
+
+type TeamExportPasswordReset struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+type TeamExportAvatar struct {
+	Path string `json:"path"`
+	Data []byte `json:"data"`
+}
+
+// TeamExport is everything stored about team. Only correct flags are stored,
+// so solves are whole submission history. Token and password hashes are not exported.
+type TeamExport struct {
+	ExportedAt     time.Time                 `json:"exported_at"`
+	Team           TeamExportProfile         `json:"team"`
+	Avatar         *TeamExportAvatar         `json:"avatar"`
+	Solves         []TaskSolvedAudit         `json:"solves"`
+	Sessions       []TeamExportSession       `json:"sessions"`
+	Tokens         []TeamToken               `json:"tokens"`
+	PasswordResets []TeamExportPasswordReset `json:"password_resets"`
+	Moderation     []TeamModeration          `json:"moderation"`
+}
+
+func (s *MainInternal) ExportTeam(ctx context.Context, teamID int) (*TeamExport, error) {
+	team, err := s.teamDB.GetByID(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get team by id: %w", err)
+	}
+
+	out := &TeamExport{
+		ExportedAt: time.Now().UTC(),
+		Team: TeamExportProfile{
+			ID:          team.ID,
+			Name:        team.Name,
+			Email:       team.Email,
+			Active:      team.Active,
+			State:       team.State,
+			Country:     team.Country,
+			Affiliation: team.Affiliation,
+			Website:     team.Website,
+			CreatedAt:   team.CreatedAt,
+			DeletedAt:   team.DeletedAt,
+		},
+	}
+
+	if len(team.AvatarPath) > 0 {
+		avatar, err := s.avatars.Get(ctx, team.AvatarPath)
+		if err != nil && err != storage.ErrNotFound {
+			return nil, fmt.Errorf("get avatar: %w", err)
+		}
+		if err == nil {
+			out.Avatar = &TeamExportAvatar{Path: team.AvatarPath, Data: avatar}
+		}
+	}
+
+	solves, err := s.auditDB.GetSolvedByTeam(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get solved by team: %w", err)
+	}
+	out.Solves = make([]TaskSolvedAudit, len(solves))
+	for i, row := range solves {
+		out.Solves[i] = TaskSolvedAudit{
+			ID:        row.TaskID,
+			Name:      row.TaskName,
+			CreatedAt: row.CreatedAt,
+		}
+	}
+
+	sessions, err := s.teamDB.GetAllSessions(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+	out.Sessions = make([]TeamExportSession, len(sessions))
+	for i, row := range sessions {
+		out.Sessions[i] = TeamExportSession{
+			ID:         row.ID,
+			UserIP:     row.UserIP,
+			UserAgent:  row.UserAgent,
+			CreatedAt:  row.CreatedAt,
+			LastSeenAt: row.LastSeenAt,
+			Revoked:    row.Revoked,
+		}
+	}
+
+	tokens, err := s.teamDB.GetAllTokens(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get tokens: %w", err)
+	}
+	out.Tokens = make([]TeamToken, len(tokens))
+	for i, row := range tokens {
+		out.Tokens[i] = TeamToken{
+			ID:         row.ID,
+			Name:       row.Name,
+			Scope:      row.Scope,
+			CreatedAt:  row.CreatedAt,
+			LastUsedAt: row.LastUsedAt,
+		}
+	}
+
+	resets, err := s.teamDB.GetPasswordResets(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get password resets: %w", err)
+	}
+	out.PasswordResets = make([]TeamExportPasswordReset, len(resets))
+	for i, row := range resets {
+		out.PasswordResets[i] = TeamExportPasswordReset{
+			CreatedAt: row.CreatedAt,
+			ExpiresAt: row.ExpiresAt,
+			UsedAt:    row.UsedAt,
+		}
+	}
+
+	moderations, err := s.teamDB.GetModerations(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get moderations: %w", err)
+	}
+	out.Moderation = make([]TeamModeration, len(moderations))
+	for i, row := range moderations {
+		out.Moderation[i] = newTeamModeration(row)
+	}
+	return out, nil
+}
+
+// DeleteAccount anonymizes team, solves are kept under anonymous name so scoreboard does not change retroactively
+func (s *MainInternal) DeleteAccount(ctx context.Context, teamID int, password string) error {
+	team, err := s.teamDB.GetByID(ctx, teamID)
+	if err != nil {
+		return fmt.Errorf("get team by id: %w", err)
+	}
+	if !team.EqualPassword(password) {
+		return InvalidCurrentPassword
+	}
+
+	if err := s.teamDB.AnonymizeTeam(ctx, teamID); err != nil {
+		return fmt.Errorf("anonymize team: %w", err)
+	}
+	s.sessionCache.DeleteTeam(teamID)
+	s.DeleteAvatar(ctx, team.AvatarPath)
+	return nil
+}
@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	defer func(start, end config.DateTimeParser) {
+		config.Config.StartCompetition, config.Config.EndCompetition = start, end
+	}(config.Config.StartCompetition, config.Config.EndCompetition)
+	config.Config.StartCompetition = config.DateTimeParser(time.Now().Add(-time.Hour))
+	config.Config.EndCompetition = config.DateTimeParser(time.Now().Add(time.Hour))
+
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "leaving")
+	if err := admin.SetTeamActive(ctx, team.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	taskID, err := s.taskDB.AddTask(ctx, models.TaskXXX{Name: "task", Category: "web", Difficult: "easy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.auditDB.AddSolve(ctx, team.ID, taskID); err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.CreateToken(ctx, team.ID, "script", models.TokenScopeRead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.DeleteAccount(ctx, team.ID, "wrong"); err != InvalidCurrentPassword {
+		t.Fatalf("delete with wrong password: %v", err)
+	}
+	if err := s.DeleteAccount(ctx, team.ID, "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := admin.GetTeam(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := fmt.Sprintf("deleted-%d", team.ID)
+	if out.Name != name || out.Email == team.Email || out.DeletedAt == nil || out.State != models.TeamStateActive {
+		t.Fatalf("anonymized team %+v", out)
+	}
+	if _, err := s.AuthenticateToken(ctx, token.Token); err != InvalidToken {
+		t.Fatalf("token of deleted team: %v", err)
+	}
+
+	// solves stay, team keeps its place under anonymous name
+	scoreboard, err := s.GetScoreboard(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scoreboard) != 1 || scoreboard[0].Team.Name != name || scoreboard[0].Points != 500 {
+		t.Fatalf("scoreboard %+v", scoreboard)
+	}
+}
+
+// TestDeleteAccountRollback personal data is not half purged when anonymization fails
+func TestDeleteAccountRollback(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	ctx := context.Background()
+	team := addTestTeam(t, s, "staying")
+	if _, err := s.CreateToken(ctx, team.ID, "script", models.TokenScopeRead); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.unsafeDB.Exec(ctx, "ALTER TABLE avatar_moderation RENAME TO avatar_moderation_off"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteAccount(ctx, team.ID, "password"); err == nil {
+		t.Fatal("account deleted without avatar_moderation table")
+	}
+	tokens, err := s.GetTokens(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 {
+		t.Fatalf("got %d tokens after failed delete, want 1", len(tokens))
+	}
+}
+
+// TestReservedTeamName team registered with name of deleted team would make the deletion fail
+func TestReservedTeamName(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "leaving")
+
+	for _, input := range []models.TeamXXX{
+		{Name: fmt.Sprintf("deleted-%d", team.ID), Email: "squatter@example.com", Country: "PL"},
+		{Name: fmt.Sprintf("Deleted-%d", team.ID), Email: "squatter@example.com", Country: "PL"},
+		{Name: "squatter", Email: fmt.Sprintf("deleted-%d@DELETED.invalid", team.ID), Country: "PL"},
+	} {
+		if err := input.SetPassword("password"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddTeam(ctx, input); err != ReservedTeamName {
+			t.Errorf("register %s %s: %v", input.Name, input.Email, err)
+		}
+		if _, err := admin.CreateTeam(ctx, AdminTeam{Name: input.Name, Email: input.Email, Country: "PL"}, "password"); err != ReservedTeamName {
+			t.Errorf("admin create %s %s: %v", input.Name, input.Email, err)
+		}
+	}
+
+	other := addTestTeam(t, s, "other")
+	rename := AdminTeam{ID: other.ID, Name: fmt.Sprintf("deleted-%d", team.ID), Email: other.Email, Country: "PL"}
+	if _, err := admin.UpdateTeam(ctx, rename, "", nil); err != ReservedTeamName {
+		t.Fatalf("admin rename to reserved name: %v", err)
+	}
+
+	if err := s.DeleteAccount(ctx, team.ID, "password"); err != nil {
+		t.Fatal(err)
+	}
+	// deleted team can still be edited by admin
+	deleted, err := admin.GetTeam(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted.Country = "DE"
+	if _, err := admin.UpdateTeam(ctx, *deleted, "", nil); err != nil {
+		t.Fatalf("admin update of deleted team: %v", err)
+	}
+}
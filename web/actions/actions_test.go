@@ -0,0 +1,63 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/db"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"ctfplatform/storage"
+	"errors"
+	"testing"
+	"time"
+)
+
+// newTestMain returns service on migrated in-memory sqlite database
+func newTestMain(t *testing.T, mailer mail.Mailer) *MainInternal {
+	t.Helper()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbSrv.Close(context.Background()) })
+	if _, err := dbSrv.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewRanking(models.NewTaskDB(dbSrv), models.NewTeamDB(dbSrv), models.NewAuditDB(dbSrv), models.NewAnnouncementDB(dbSrv), mailer, storage.NewDBStorage(dbSrv), dbSrv)
+}
+
+// addTestTeam password is "password"
+func addTestTeam(t *testing.T, s *MainInternal, name string) *models.TeamXXX {
+	t.Helper()
+	ctx := context.Background()
+	team := models.TeamXXX{Name: name, Email: name + "@example.com", Country: "PL"}
+	if err := team.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTeam(ctx, team); err != nil {
+		t.Fatal(err)
+	}
+	out, err := s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestWait(t *testing.T) {
+	s := newTestMain(t, nil)
+	release := make(chan struct{})
+	s.goBackground(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait with running job: %v", err)
+	}
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatalf("wait after job is done: %v", err)
+	}
+}
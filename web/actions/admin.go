@@ -0,0 +1,594 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/log"
+	"ctfplatform/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+var NotFound = errors.New("not found")
+var AlreadyExists = errors.New("already exists")
+var HasSolves = errors.New("has solves")
+var HasModeration = errors.New("has moderation history")
+
+// AdminInternal is used by admin api, it is not checking competition time or freeze
+type AdminInternal struct {
+	main *MainInternal
+}
+
+func NewAdmin(main *MainInternal) *AdminInternal {
+	return &AdminInternal{
+		main: main,
+	}
+}
+
+func wrapAdminErr(err error) error {
+	if err == sql.ErrNoRows {
+		return NotFound
+	} else if err == db.ErrAlreadyExistsDB {
+		return AlreadyExists
+	} else if err == db.ErrReferencedDB {
+		return HasSolves
+	}
+	return err
+}
+
+// reloadFlags flags should be submittable right after change, other instances reload after seeing new version
+func (s *AdminInternal) reloadFlags(ctx context.Context) {
+	if err := s.main.taskDB.BumpFlagsVersion(ctx); err != nil {
+		log.Log.WithError(err).Error("bump flags version err")
+	}
+	if err := s.main.taskDB.Reload(ctx); err != nil {
+		log.Log.WithError(err).Error("reload flags err")
+	}
+}
+
+type AdminFlagCache struct {
+	Version     int64      `json:"version"`
+	Flags       int        `json:"flags"`
+	ReloadedAt  *time.Time `json:"reloaded_at"`
+	CheckedAt   *time.Time `json:"checked_at"`
+	NextStartAt *time.Time `json:"next_start_at"`
+}
+
+// GetFlagCache state of flags cache of this instance
+func (s *AdminInternal) GetFlagCache() AdminFlagCache {
+	state := s.main.taskDB.FlagsCacheState()
+	out := AdminFlagCache{
+		Version:     state.Version,
+		Flags:       state.Flags,
+		NextStartAt: state.NextStartAt,
+	}
+	if !state.ReloadedAt.IsZero() {
+		out.ReloadedAt = &state.ReloadedAt
+		out.CheckedAt = &state.CheckedAt
+	}
+	return out
+}
+
+// ReloadFlagCache reloads flags on every instance
+func (s *AdminInternal) ReloadFlagCache(ctx context.Context) (*AdminFlagCache, error) {
+	if err := s.main.taskDB.BumpFlagsVersion(ctx); err != nil {
+		return nil, fmt.Errorf("bump flags version: %w", err)
+	}
+	if err := s.main.taskDB.Reload(ctx); err != nil {
+		return nil, fmt.Errorf("reload flags: %w", err)
+	}
+	out := s.GetFlagCache()
+	return &out, nil
+}
+
+/// tasks
+
+type AdminTask struct {
+	ID          int        `json:"id"`
+	Slug        *string    `json:"slug"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Category    string     `json:"category"`
+	Difficult   string     `json:"difficult"`
+	StartedAt   *time.Time `json:"started_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+func newAdminTask(row *models.TaskXXX) AdminTask {
+	return AdminTask{
+		ID:          row.ID,
+		Slug:        row.Slug,
+		Name:        row.Name,
+		Description: row.Description,
+		Category:    row.Category,
+		Difficult:   row.Difficult,
+		StartedAt:   row.StartedAt,
+		CreatedAt:   row.CreatedAt,
+	}
+}
+
+func (s *AdminInternal) GetTasks(ctx context.Context) ([]AdminTask, error) {
+	rows, err := s.main.taskDB.AdminAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get tasks: %w", err)
+	}
+
+	out := make([]AdminTask, len(rows))
+	for i, row := range rows {
+		out[i] = newAdminTask(row)
+	}
+	return out, nil
+}
+
+func (s *AdminInternal) GetTask(ctx context.Context, taskID int) (*AdminTask, error) {
+	row, err := s.main.taskDB.GetByID(ctx, taskID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	out := newAdminTask(row)
+	return &out, nil
+}
+
+func (s *AdminInternal) CreateTask(ctx context.Context, task AdminTask) (*AdminTask, error) {
+	taskID, err := s.main.taskDB.AddTask(ctx, models.TaskXXX{
+		Slug:        task.Slug,
+		Name:        task.Name,
+		Description: task.Description,
+		Category:    task.Category,
+		Difficult:   task.Difficult,
+		StartedAt:   task.StartedAt,
+	})
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	// next start could change
+	s.reloadFlags(ctx)
+	return s.GetTask(ctx, taskID)
+}
+
+// UpdateTask keeps current slug when not given, slug is used by challenge import
+func (s *AdminInternal) UpdateTask(ctx context.Context, task AdminTask) (*AdminTask, error) {
+	current, err := s.GetTask(ctx, task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Slug == nil {
+		task.Slug = current.Slug
+	}
+	err = s.main.taskDB.UpdateTask(ctx, models.TaskXXX{
+		ID:          task.ID,
+		Slug:        task.Slug,
+		Name:        task.Name,
+		Description: task.Description,
+		Category:    task.Category,
+		Difficult:   task.Difficult,
+		StartedAt:   task.StartedAt,
+	})
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	// started_at could change
+	s.reloadFlags(ctx)
+	return s.GetTask(ctx, task.ID)
+}
+
+func (s *AdminInternal) DeleteTask(ctx context.Context, taskID int) error {
+	if _, err := s.GetTask(ctx, taskID); err != nil {
+		return err
+	}
+	solved, err := s.main.auditDB.CountSolvedByTask(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("count solved by task: %w", err)
+	}
+	if solved > 0 {
+		return HasSolves
+	}
+	if err := s.main.taskDB.DeleteTask(ctx, taskID); err != nil {
+		return wrapAdminErr(err)
+	}
+	s.reloadFlags(ctx)
+	return nil
+}
+
+/// task flags
+
+type AdminFlag struct {
+	ID     int    `json:"id"`
+	TaskID int    `json:"task_id"`
+	Flag   string `json:"flag"`
+}
+
+func (s *AdminInternal) GetFlags(ctx context.Context, taskID int) ([]AdminFlag, error) {
+	if _, err := s.GetTask(ctx, taskID); err != nil {
+		return nil, err
+	}
+	rows, err := s.main.taskDB.GetTaskFlags(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("get task flags: %w", err)
+	}
+
+	out := make([]AdminFlag, len(rows))
+	for i, row := range rows {
+		out[i] = AdminFlag{
+			ID:     row.ID,
+			TaskID: row.TaskID,
+			Flag:   row.Flag,
+		}
+	}
+	return out, nil
+}
+
+func (s *AdminInternal) AddFlag(ctx context.Context, taskID int, flag string) (*AdminFlag, error) {
+	if _, err := s.GetTask(ctx, taskID); err != nil {
+		return nil, err
+	}
+	flagID, err := s.main.taskDB.AddFlag(ctx, taskID, flag)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	s.reloadFlags(ctx)
+	return &AdminFlag{
+		ID:     flagID,
+		TaskID: taskID,
+		Flag:   flag,
+	}, nil
+}
+
+func (s *AdminInternal) DeleteFlag(ctx context.Context, taskID int, flagID int) error {
+	if err := s.main.taskDB.DeleteFlag(ctx, taskID, flagID); err != nil {
+		return wrapAdminErr(err)
+	}
+	s.reloadFlags(ctx)
+	return nil
+}
+
+/// announcements
+
+func (s *AdminInternal) GetAnnouncements(ctx context.Context) ([]Announcement, error) {
+	return s.main.GetAnnouncements(ctx)
+}
+
+func (s *AdminInternal) GetAnnouncement(ctx context.Context, announcementID int) (*Announcement, error) {
+	row, err := s.main.announcementDB.GetByID(ctx, announcementID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	return &Announcement{
+		ID:          row.ID,
+		Title:       row.Title,
+		Description: row.Description,
+		CreatedAt:   row.CreatedAt,
+	}, nil
+}
+
+func (s *AdminInternal) CreateAnnouncement(ctx context.Context, announcement Announcement) (*Announcement, error) {
+	announcementID, err := s.main.announcementDB.Add(ctx, models.AnnouncementXXX{
+		Title:       announcement.Title,
+		Description: announcement.Description,
+	})
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	return s.GetAnnouncement(ctx, announcementID)
+}
+
+func (s *AdminInternal) UpdateAnnouncement(ctx context.Context, announcement Announcement) (*Announcement, error) {
+	if _, err := s.GetAnnouncement(ctx, announcement.ID); err != nil {
+		return nil, err
+	}
+	err := s.main.announcementDB.Update(ctx, models.AnnouncementXXX{
+		ID:          announcement.ID,
+		Title:       announcement.Title,
+		Description: announcement.Description,
+	})
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	return s.GetAnnouncement(ctx, announcement.ID)
+}
+
+func (s *AdminInternal) DeleteAnnouncement(ctx context.Context, announcementID int) error {
+	return wrapAdminErr(s.main.announcementDB.Delete(ctx, announcementID))
+}
+
+/// teams
+
+type AdminTeam struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Email       string     `json:"email"`
+	Active      bool       `json:"active"`
+	State       string     `json:"state"`
+	StateReason string     `json:"state_reason"`
+	Country     string     `json:"country"`
+	Affiliation string     `json:"affiliation"`
+	Website     string     `json:"website"`
+	Avatar      string     `json:"avatar"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+func newAdminTeam(row *models.TeamXXX) AdminTeam {
+	return AdminTeam{
+		ID:          row.ID,
+		Name:        row.Name,
+		Email:       row.Email,
+		Active:      row.Active,
+		State:       row.State,
+		StateReason: row.StateReason,
+		Country:     row.Country,
+		Affiliation: row.Affiliation,
+		Website:     row.Website,
+		Avatar:      row.AvatarPath,
+		CreatedAt:   row.CreatedAt,
+		DeletedAt:   row.DeletedAt,
+	}
+}
+
+func (s *AdminInternal) GetTeams(ctx context.Context) ([]AdminTeam, error) {
+	rows, err := s.main.teamDB.AdminAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get teams: %w", err)
+	}
+
+	out := make([]AdminTeam, len(rows))
+	for i, row := range rows {
+		out[i] = newAdminTeam(row)
+	}
+	return out, nil
+}
+
+func (s *AdminInternal) GetTeam(ctx context.Context, teamID int) (*AdminTeam, error) {
+	row, err := s.main.teamDB.GetByID(ctx, teamID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	out := newAdminTeam(row)
+	return &out, nil
+}
+
+func (s *AdminInternal) CreateTeam(ctx context.Context, team AdminTeam, password string) (*AdminTeam, error) {
+	if err := CheckTeamName(team.Name, team.Email); err != nil {
+		return nil, err
+	}
+	teamInput := models.TeamXXX{
+		Name:    team.Name,
+		Email:   team.Email,
+		Active:  team.Active,
+		Country: team.Country,
+	}
+	if err := teamInput.SetPassword(password); err != nil {
+		return nil, fmt.Errorf("set password: %w", err)
+	}
+
+	teamID, err := s.main.teamDB.AddTeam(ctx, teamInput)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	if len(team.Affiliation) > 0 || len(team.Website) > 0 {
+		teamInput.ID = teamID
+		teamInput.Affiliation = team.Affiliation
+		teamInput.Website = team.Website
+		if err := s.main.teamDB.UpdateTeam(ctx, teamInput); err != nil {
+			return nil, wrapAdminErr(err)
+		}
+	}
+	return s.GetTeam(ctx, teamID)
+}
+
+// UpdateTeam changes password only when not empty, all team sessions are revoked then.
+// team.Active is ignored, active is changed only when not nil.
+func (s *AdminInternal) UpdateTeam(ctx context.Context, team AdminTeam, password string, active *bool) (*AdminTeam, error) {
+	teamData, err := s.main.teamDB.GetByID(ctx, team.ID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	// deleted team keeps its reserved name
+	if team.Name != teamData.Name || team.Email != teamData.Email {
+		if err := CheckTeamName(team.Name, team.Email); err != nil {
+			return nil, err
+		}
+	}
+
+	teamData.Name = team.Name
+	teamData.Email = team.Email
+	teamData.Country = team.Country
+	teamData.Affiliation = team.Affiliation
+	teamData.Website = team.Website
+	if len(password) > 0 {
+		if err := teamData.SetPassword(password); err != nil {
+			return nil, fmt.Errorf("set password: %w", err)
+		}
+	}
+	err = s.main.tx(ctx, func(ctx context.Context) error {
+		if err := s.main.teamDB.UpdateTeam(ctx, *teamData); err != nil {
+			return wrapAdminErr(err)
+		}
+		if active != nil && teamData.Active != *active {
+			if err := s.main.teamDB.SetActive(ctx, team.ID, *active); err != nil {
+				return wrapAdminErr(err)
+			}
+		}
+		if len(password) > 0 {
+			if _, err := s.main.RevokeSessions(ctx, team.ID, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(password) > 0 {
+		s.main.sessionCache.DeleteTeam(team.ID)
+	}
+	return s.GetTeam(ctx, team.ID)
+}
+
+// DeleteTeam is only for teams which never played nor were moderated, others should be banned or hidden,
+// so solves and moderation history stay
+func (s *AdminInternal) DeleteTeam(ctx context.Context, teamID int) error {
+	team, err := s.GetTeam(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	err = s.main.tx(ctx, func(ctx context.Context) error {
+		solved, err := s.main.auditDB.CountSolvedByTeam(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("count solved by team: %w", err)
+		}
+		if solved > 0 {
+			return HasSolves
+		}
+		moderations, err := s.main.teamDB.GetModerations(ctx, teamID)
+		if err != nil {
+			return fmt.Errorf("get moderations: %w", err)
+		}
+		if len(moderations) > 0 {
+			return HasModeration
+		}
+		return wrapAdminErr(s.main.teamDB.DeleteTeam(ctx, teamID))
+	})
+	if err != nil {
+		return err
+	}
+	s.main.sessionCache.DeleteTeam(teamID)
+	s.main.DeleteAvatar(ctx, team.Avatar)
+	return nil
+}
+
+func (s *AdminInternal) RevokeTeamSessions(ctx context.Context, teamID int) error {
+	if _, err := s.GetTeam(ctx, teamID); err != nil {
+		return err
+	}
+	_, err := s.main.RevokeSessions(ctx, teamID, 0)
+	return err
+}
+
+// SetTeamActive inactive team has not verified email yet, use SetTeamState for moderation
+func (s *AdminInternal) SetTeamActive(ctx context.Context, teamID int, active bool) error {
+	if _, err := s.GetTeam(ctx, teamID); err != nil {
+		return err
+	}
+	return wrapAdminErr(s.main.teamDB.SetActive(ctx, teamID, active))
+}
+
+// ResetTeamPassword sets new password and logs team out everywhere
+func (s *AdminInternal) ResetTeamPassword(ctx context.Context, teamID int, password string) error {
+	team, err := s.main.teamDB.GetByID(ctx, teamID)
+	if err != nil {
+		return wrapAdminErr(err)
+	}
+	if err := team.SetPassword(password); err != nil {
+		return fmt.Errorf("set password: %w", err)
+	}
+	err = s.main.tx(ctx, func(ctx context.Context) error {
+		if err := s.main.teamDB.UpdatePassword(ctx, teamID, team.Password); err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+		_, err := s.main.RevokeSessions(ctx, teamID, 0)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.main.sessionCache.DeleteTeam(teamID)
+	return nil
+}
+
+/// solves
+
+var InvalidFilter = errors.New("invalid filter")
+
+const (
+	solvesDefaultLimit = 100
+	solvesMaxLimit     = 1000
+)
+
+type AdminSolve struct {
+	ID        int       `json:"id"`
+	TeamID    int       `json:"team_id"`
+	TeamName  string    `json:"team_name"`
+	TaskID    int       `json:"task_id"`
+	TaskName  string    `json:"task_name"`
+	CreatedAt time.Time `json:"created_at"`
+	// solved during freeze, not shown on scoreboard until it ends
+	Freeze bool `json:"freeze"`
+}
+
+// AdminSolveFilter cursor is next_cursor of previous page, Freeze nil lists solves from whole competition
+type AdminSolveFilter struct {
+	TeamID int
+	TaskID int
+	From   time.Time
+	To     time.Time
+	Freeze *bool
+	Cursor string
+	Limit  int
+}
+
+type AdminSolvesPage struct {
+	Solves []AdminSolve `json:"solves"`
+	// empty on last page
+	NextCursor string `json:"next_cursor"`
+}
+
+// GetSolves returns newest solves first, cursor stays valid while new solves are added
+func (s *AdminInternal) GetSolves(ctx context.Context, filter AdminSolveFilter) (*AdminSolvesPage, error) {
+	if filter.Limit == 0 {
+		filter.Limit = solvesDefaultLimit
+	}
+	if filter.Limit < 0 || filter.Limit > solvesMaxLimit {
+		return nil, InvalidFilter
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, InvalidFilter
+	}
+	beforeID := 0
+	if len(filter.Cursor) > 0 {
+		id, err := strconv.Atoi(filter.Cursor)
+		if err != nil || id <= 0 {
+			return nil, InvalidFilter
+		}
+		beforeID = id
+	}
+
+	// one more row tells if there is next page
+	rows, err := s.main.auditDB.GetAudits(ctx, models.AuditFilter{
+		TeamID:   filter.TeamID,
+		TaskID:   filter.TaskID,
+		From:     filter.From,
+		To:       filter.To,
+		Freeze:   filter.Freeze,
+		BeforeID: beforeID,
+		Limit:    filter.Limit + 1,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get audits: %w", err)
+	}
+
+	out := &AdminSolvesPage{}
+	if len(rows) > filter.Limit {
+		rows = rows[:filter.Limit]
+		out.NextCursor = strconv.Itoa(rows[len(rows)-1].ID)
+	}
+	freezeStart := time.Time(config.Config.FreezeStartCompetition)
+	freezeEnd := time.Time(config.Config.FreezeEndCompetition)
+	out.Solves = make([]AdminSolve, len(rows))
+	for i, row := range rows {
+		out.Solves[i] = AdminSolve{
+			ID:        row.ID,
+			TeamID:    row.TeamID,
+			TeamName:  row.TeamName,
+			TaskID:    row.TaskID,
+			TaskName:  row.TaskName,
+			CreatedAt: row.CreatedAt,
+			Freeze:    !row.CreatedAt.Before(freezeStart) && row.CreatedAt.Before(freezeEnd),
+		}
+	}
+	return out, nil
+}
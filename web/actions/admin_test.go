@@ -0,0 +1,149 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"testing"
+)
+
+func TestUpdateTeamKeepsActive(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "active")
+	if err := admin.SetTeamActive(ctx, team.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	input := AdminTeam{ID: team.ID, Name: "renamed", Email: team.Email, Country: team.Country}
+	out, err := admin.UpdateTeam(ctx, input, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !out.Active || out.Name != "renamed" {
+		t.Fatalf("active %v, name %s", out.Active, out.Name)
+	}
+
+	active := false
+	out, err = admin.UpdateTeam(ctx, input, "", &active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Active {
+		t.Fatal("team still active")
+	}
+}
+
+func TestDeleteTeam(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+
+	team := addTestTeam(t, s, "deleted")
+	if _, err := s.CreateToken(ctx, team.ID, "script", models.TokenScopeRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTeam(ctx, team.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.GetTeam(ctx, team.ID); err != NotFound {
+		t.Fatalf("deleted team: %v", err)
+	}
+
+	moderated := addTestTeam(t, s, "moderated")
+	if _, err := admin.SetTeamState(ctx, moderated.ID, "root", models.TeamStateBanned, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTeam(ctx, moderated.ID); err != HasModeration {
+		t.Fatalf("delete moderated team: %v", err)
+	}
+	moderations, err := admin.GetTeamModerations(ctx, moderated.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moderations) != 1 {
+		t.Fatalf("got %d moderations, want 1", len(moderations))
+	}
+}
+
+// TestResetTeamPasswordRollback password must not change when sessions can not be revoked
+func TestResetTeamPasswordRollback(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "reset")
+
+	if _, err := s.unsafeDB.Exec(ctx, "ALTER TABLE team_session RENAME TO team_session_off"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.ResetTeamPassword(ctx, team.ID, "newpassword"); err == nil {
+		t.Fatal("reset succeeded without sessions table")
+	}
+	input := AdminTeam{ID: team.ID, Name: "renamed", Email: team.Email, Country: team.Country}
+	if _, err := admin.UpdateTeam(ctx, input, "newpassword", nil); err == nil {
+		t.Fatal("update succeeded without sessions table")
+	}
+	if _, err := s.unsafeDB.Exec(ctx, "ALTER TABLE team_session_off RENAME TO team_session"); err != nil {
+		t.Fatal(err)
+	}
+
+	teamData, err := s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !teamData.EqualPassword("password") || teamData.Name != team.Name {
+		t.Fatalf("team changed by failed update, name %s", teamData.Name)
+	}
+
+	sessionID, err := s.NewSession(ctx, team.ID, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.ResetTeamPassword(ctx, team.ID, "newpassword"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateSession(ctx, team.ID, sessionID, teamData.SessionVersion); err != SessionRevoked {
+		t.Fatalf("session after password reset: %v", err)
+	}
+}
+
+// TestDeleteTaskRollback flags must stay when task can not be deleted
+func TestDeleteTaskRollback(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+
+	task, err := admin.CreateTask(ctx, AdminTask{Name: "task", Category: "web", Difficult: "easy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.AddFlag(ctx, task.ID, "ctf{flag}"); err != nil {
+		t.Fatal(err)
+	}
+
+	// flags are deleted first, task delete fails after them
+	if _, err := s.unsafeDB.Exec(ctx, "CREATE TRIGGER keep_task BEFORE DELETE ON task BEGIN SELECT RAISE(ABORT, 'kept'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTask(ctx, task.ID); err == nil {
+		t.Fatal("delete succeeded with trigger")
+	}
+	flags, err := admin.GetFlags(ctx, task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flags) != 1 {
+		t.Fatalf("got %d flags after failed delete, want 1", len(flags))
+	}
+
+	if _, err := s.unsafeDB.Exec(ctx, "DROP TRIGGER keep_task"); err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.DeleteTask(ctx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.GetTask(ctx, task.ID); err != NotFound {
+		t.Fatalf("deleted task: %v", err)
+	}
+}
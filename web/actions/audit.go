@@ -2,14 +2,19 @@ package actions
 
 import (
 	"context"
+	"ctfplatform/avatar"
 	"ctfplatform/config"
 	"ctfplatform/db"
+	"ctfplatform/log"
+	"ctfplatform/mail"
 	"ctfplatform/models"
+	"ctfplatform/storage"
 	"database/sql"
 	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,7 +22,16 @@ type MainInternal struct {
 	taskDB *models.TaskInternal
 	teamDB *models.TeamInternal
 
-	auditDB *models.AuditInternal
+	auditDB        *models.AuditInternal
+	announcementDB *models.AnnouncementInternal
+
+	mailer  mail.Mailer
+	avatars storage.Storage
+
+	sessionCache *sessionCache
+
+	// mails and other work which outlives request
+	background sync.WaitGroup
 
 	unsafeDB *db.DatabaseInternal   // TODO: remove it, added because deadline is coming :x
 }
@@ -26,18 +40,54 @@ var InvalidFlag = errors.New("invalid flag")
 var AlreadySolved = errors.New("already solved task")
 var TeamNotFound = errors.New("team not found")
 var TeamAlreadyExists = errors.New("team already exists")
+var TeamNotActive = errors.New("team not active")
 
-func NewRanking(taskDB *models.TaskInternal, teamDB *models.TeamInternal, auditDB *models.AuditInternal, unsafeDB *db.DatabaseInternal) *MainInternal {
+func NewRanking(taskDB *models.TaskInternal, teamDB *models.TeamInternal, auditDB *models.AuditInternal, announcementDB *models.AnnouncementInternal, mailer mail.Mailer, avatars storage.Storage, unsafeDB *db.DatabaseInternal) *MainInternal {
 	s := &MainInternal{
-		taskDB:  taskDB,
-		teamDB:  teamDB,
-		auditDB: auditDB,
+		taskDB:         taskDB,
+		teamDB:         teamDB,
+		auditDB:        auditDB,
+		announcementDB: announcementDB,
+
+		mailer:  mailer,
+		avatars: avatars,
+
+		sessionCache: newSessionCache(),
 
 		unsafeDB: unsafeDB,
 	}
 	return s
 }
 
+// goBackground runs f in goroutine tracked by Wait
+func (s *MainInternal) goBackground(f func()) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		f()
+	}()
+}
+
+// tx runs f in one database transaction, everything done with ctx passed to f is rolled back on error
+func (s *MainInternal) tx(ctx context.Context, f func(ctx context.Context) error) error {
+	return s.unsafeDB.Tx(ctx, f)
+}
+
+// Wait blocks until background work is done or ctx expires
+func (s *MainInternal) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // game info
 type Info struct {
 	Start              time.Time `json:"start"`
@@ -46,6 +96,8 @@ type Info struct {
 	TeamsCount         int       `json:"teams_count"`
 	CountriesCount     int       `json:"countries_count"`
 	TasksUnsolvedCount int       `json:"tasks_unsolved_count"`
+	CaptchaProvider    string    `json:"captcha_provider"`
+	CaptchaSiteKey     string    `json:"captcha_site_key"`
 }
 
 func (s *MainInternal) GetInfo(ctx context.Context) (Info, error) {
@@ -93,6 +145,9 @@ FROM
 	info := Info{
 		Start: time.Time(config.Config.StartCompetition),
 		End:   time.Time(config.Config.EndCompetition),
+		// frontend renders widget of provider which backend verifies
+		CaptchaProvider: config.Config.CaptchaProvider,
+		CaptchaSiteKey:  config.Config.CaptchaSiteKey,
 	}
 	if err := s.unsafeDB.QueryRow(ctx, query, info.Start, info.End, info.End, info.End, info.Start, info.End).Scan(&info.FlagsCount, &info.TeamsCount, &info.CountriesCount, &info.TasksUnsolvedCount); err != nil {
 		return info, err
@@ -103,6 +158,14 @@ FROM
 // solve
 
 func (s *MainInternal) Solve(ctx context.Context, teamID int, flag string) error {
+	team, err := s.teamDB.GetByID(ctx, teamID)
+	if err != nil {
+		return fmt.Errorf("get team by id: %w", err)
+	}
+	if err := CheckTeamCanSubmit(team); err != nil {
+		return err
+	}
+
 	taskID, err := s.taskDB.GetByFlag(ctx, flag)
 	if err != nil {
 		return InvalidFlag
@@ -164,7 +227,7 @@ type Announcement struct {
 }
 
 func (s *MainInternal) GetAnnouncements(ctx context.Context) ([]Announcement, error) {
-	rows, err := s.auditDB.GetAnnouncements(ctx)
+	rows, err := s.announcementDB.All(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("get announcements: %w", err)
 	}
@@ -261,6 +324,34 @@ type TeamData struct {
 	Website     string            `json:"website,omitempty"`
 	CreatedAt   time.Time         `json:"created_at"`
 	TaskSolved  []TaskSolvedAudit `json:"task_solved,omitempty"`
+	// only for own team, pending or rejected upload
+	AvatarModeration *AvatarModerationStatus `json:"avatar_moderation,omitempty"`
+}
+
+// avatarURL teams without uploaded avatar get generated one
+func avatarURL(teamID int, avatarPath string) string {
+	if len(avatarPath) == 0 {
+		return filepath.Join(config.Config.AvatarPublicWebPath, avatar.IdenticonPath(teamID))
+	}
+	if len(config.Config.AvatarStorageUrl) > 0 {
+		// served by nginx or bucket, not by app
+		return strings.TrimRight(config.Config.AvatarStorageUrl, "/") + "/" + avatarPath
+	}
+	return filepath.Join(config.Config.AvatarPublicWebPath, avatarPath)
+}
+
+func (s *MainInternal) GetAvatarStorage() storage.Storage {
+	return s.avatars
+}
+
+// DeleteAvatar used for removed and replaced avatars, failure leaves only unreferenced object
+func (s *MainInternal) DeleteAvatar(ctx context.Context, avatarPath string) {
+	if len(avatarPath) == 0 {
+		return
+	}
+	if err := s.avatars.Delete(ctx, avatarPath); err != nil {
+		log.Log.WithError(err).WithField("avatar", avatarPath).Error("delete avatar err")
+	}
 }
 
 func (s *MainInternal) GetTeams(ctx context.Context) ([]*TeamData, error) {
@@ -280,9 +371,7 @@ func (s *MainInternal) GetTeams(ctx context.Context) ([]*TeamData, error) {
 			Website:     team.Website,
 			Affiliation: team.Affiliation,
 		}
-		if len(team.AvatarPath) > 0 {
-			outTeam.Avatar = filepath.Join(config.Config.AvatarPublicWebPath, team.AvatarPath)
-		}
+		outTeam.Avatar = avatarURL(team.ID, team.AvatarPath)
 		out[i] = outTeam
 	}
 	return out, nil
@@ -302,9 +391,7 @@ func (s *MainInternal) GetTeamDataPublic(ctx context.Context, teamID int) (*Team
 		Website:     team.Website,
 		Affiliation: team.Affiliation,
 	}
-	if len(team.AvatarPath) > 0 {
-		outTeam.Avatar = filepath.Join(config.Config.AvatarPublicWebPath, team.AvatarPath)
-	}
+	outTeam.Avatar = avatarURL(team.ID, team.AvatarPath)
 
 	rows, err := s.auditDB.GetSolvedByTeam(ctx, teamID)
 	if err != nil {
@@ -342,8 +429,14 @@ func (s *MainInternal) GetTeamDataPrivate(ctx context.Context, teamID int) (*Tea
 		Website:     team.Website,
 		Affiliation: team.Affiliation,
 	}
-	if len(team.AvatarPath) > 0 {
-		outTeam.Avatar = filepath.Join(config.Config.AvatarPublicWebPath, team.AvatarPath)
+	outTeam.Avatar = avatarURL(team.ID, team.AvatarPath)
+
+	if config.Config.AvatarModeration {
+		status, err := s.getAvatarModerationStatus(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		outTeam.AvatarModeration = status
 	}
 
 	rows, err := s.auditDB.GetSolvedByTeam(ctx, teamID)
@@ -392,9 +485,7 @@ func (s *MainInternal) GetTeamDataByIds(ctx context.Context, teamIds []int) (map
 			Country:   team.Country,
 			CreatedAt: team.CreatedAt,
 		}
-		if len(team.AvatarPath) > 0 {
-			out.Avatar = filepath.Join(config.Config.AvatarPublicWebPath, team.AvatarPath)
-		}
+		out.Avatar = avatarURL(team.ID, team.AvatarPath)
 
 		if solvedTasks, exists := solvedTasks[teamID]; exists {
 			tasksOut := make([]TaskSolvedAudit, 0, len(solvedTasks))
@@ -420,11 +511,23 @@ func (s *MainInternal) GetTeamDataByIds(ctx context.Context, teamIds []int) (map
 // add team
 
 func (s *MainInternal) AddTeam(ctx context.Context, teamData models.TeamXXX) error {
-	err := s.teamDB.AddTeam(ctx, teamData)
+	if err := CheckTeamName(teamData.Name, teamData.Email); err != nil {
+		return err
+	}
+	teamData.Active = !config.Config.RequireEmailVerification
+
+	teamID, err := s.teamDB.AddTeam(ctx, teamData)
 	if err == db.ErrAlreadyExistsDB {
 		return TeamAlreadyExists
+	} else if err != nil {
+		return err
+	}
+	teamData.ID = teamID
+
+	if !teamData.Active {
+		return s.SendVerificationEmail(teamData)
 	}
-	return err
+	return nil
 }
 
 // get team
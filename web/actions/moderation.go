@@ -0,0 +1,301 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var TeamBanned = errors.New("team banned")
+var TeamDisqualified = errors.New("team disqualified")
+var InvalidTeamState = errors.New("invalid team state")
+var InvalidReason = errors.New("invalid reason")
+
+// team states:
+//   - hidden: plays normally, but is not shown on scoreboard and teams list (organizers, out of competition)
+//   - banned: can not log in nor submit, solves lower task points only when BannedTeamSolvesCount is set
+//   - disqualified: can log in, can not submit, solves are ignored everywhere
+var teamStates = map[string]bool{
+	models.TeamStateActive:       true,
+	models.TeamStateHidden:       true,
+	models.TeamStateBanned:       true,
+	models.TeamStateDisqualified: true,
+}
+
+// CheckTeamCanLogin returns TeamBanned or TeamNotActive
+func CheckTeamCanLogin(team *models.TeamXXX) error {
+	if team.State == models.TeamStateBanned {
+		return TeamBanned
+	}
+	if !team.Active && !config.Config.InactiveTeamCanLogin {
+		return TeamNotActive
+	}
+	return nil
+}
+
+// CheckTeamCanSubmit returns TeamBanned, TeamDisqualified or TeamNotActive
+func CheckTeamCanSubmit(team *models.TeamXXX) error {
+	switch team.State {
+	case models.TeamStateBanned:
+		return TeamBanned
+	case models.TeamStateDisqualified:
+		return TeamDisqualified
+	}
+	if !team.Active && !config.Config.InactiveTeamCanSubmit {
+		return TeamNotActive
+	}
+	return nil
+}
+
+type TeamModeration struct {
+	ID        int       `json:"id"`
+	TeamID    int       `json:"team_id"`
+	Admin     string    `json:"admin"`
+	State     string    `json:"state"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newTeamModeration(row *models.TeamModerationXXX) TeamModeration {
+	return TeamModeration{
+		ID:        row.ID,
+		TeamID:    row.TeamID,
+		Admin:     row.Admin,
+		State:     row.State,
+		Reason:    row.Reason,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+// SetTeamState changes team state and logs it with acting admin, reason is required for ban and disqualification
+func (s *AdminInternal) SetTeamState(ctx context.Context, teamID int, admin string, state string, reason string) (*AdminTeam, error) {
+	if !teamStates[state] {
+		return nil, InvalidTeamState
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > 255 {
+		return nil, InvalidReason
+	}
+	if len(reason) == 0 && (state == models.TeamStateBanned || state == models.TeamStateDisqualified) {
+		return nil, InvalidReason
+	}
+	if _, err := s.GetTeam(ctx, teamID); err != nil {
+		return nil, err
+	}
+
+	// state without log entry or banned team with live sessions must not be visible to other requests
+	err := s.main.tx(ctx, func(ctx context.Context) error {
+		if err := s.main.teamDB.SetState(ctx, teamID, state, reason); err != nil {
+			return wrapAdminErr(err)
+		}
+		if _, err := s.main.teamDB.AddModeration(ctx, teamID, admin, state, reason); err != nil {
+			return fmt.Errorf("add moderation: %w", err)
+		}
+		if state == models.TeamStateBanned {
+			if _, err := s.main.RevokeSessions(ctx, teamID, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if state == models.TeamStateBanned {
+		s.main.sessionCache.DeleteTeam(teamID)
+	}
+	return s.GetTeam(ctx, teamID)
+}
+
+func (s *AdminInternal) GetTeamModerations(ctx context.Context, teamID int) ([]TeamModeration, error) {
+	if _, err := s.GetTeam(ctx, teamID); err != nil {
+		return nil, err
+	}
+	rows, err := s.main.teamDB.GetModerations(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get moderations: %w", err)
+	}
+
+	out := make([]TeamModeration, len(rows))
+	for i, row := range rows {
+		out[i] = newTeamModeration(row)
+	}
+	return out, nil
+}
+
+/// avatars
+
+var AvatarNotPending = errors.New("avatar not pending")
+var InvalidAvatarState = errors.New("invalid avatar moderation state")
+
+// AvatarModerationStatus is shown to team, reason tells why avatar was rejected
+type AvatarModerationStatus struct {
+	State      string     `json:"state"`
+	Reason     string     `json:"reason,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// getAvatarModerationStatus returns nil when there is nothing to tell, approved avatar is already public
+func (s *MainInternal) getAvatarModerationStatus(ctx context.Context, teamID int) (*AvatarModerationStatus, error) {
+	row, err := s.teamDB.GetLastAvatarModeration(ctx, teamID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get last avatar moderation: %w", err)
+	}
+	if row.State == models.AvatarModerationApproved {
+		return nil, nil
+	}
+	return &AvatarModerationStatus{
+		State:      row.State,
+		Reason:     row.Reason,
+		CreatedAt:  row.CreatedAt,
+		ReviewedAt: row.ReviewedAt,
+	}, nil
+}
+
+// SubmitAvatar queues avatar for review, team keeps current avatar until it is approved
+func (s *MainInternal) SubmitAvatar(ctx context.Context, teamID int, avatarPayload []byte) error {
+	if _, err := s.teamDB.AddAvatarModeration(ctx, teamID, avatarPayload); err != nil {
+		return fmt.Errorf("add avatar moderation: %w", err)
+	}
+	return nil
+}
+
+type AdminAvatarModeration struct {
+	ID         int        `json:"id"`
+	TeamID     int        `json:"team_id"`
+	TeamName   string     `json:"team_name"`
+	State      string     `json:"state"`
+	Reason     string     `json:"reason"`
+	Admin      string     `json:"admin"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+func newAdminAvatarModeration(row *models.AvatarModerationXXX) AdminAvatarModeration {
+	return AdminAvatarModeration{
+		ID:         row.ID,
+		TeamID:     row.TeamID,
+		TeamName:   row.TeamName,
+		State:      row.State,
+		Reason:     row.Reason,
+		Admin:      row.Admin,
+		CreatedAt:  row.CreatedAt,
+		ReviewedAt: row.ReviewedAt,
+	}
+}
+
+var avatarModerationStates = map[string]bool{
+	models.AvatarModerationPending:  true,
+	models.AvatarModerationApproved: true,
+	models.AvatarModerationRejected: true,
+}
+
+// GetAvatarModerations empty state lists pending queue
+func (s *AdminInternal) GetAvatarModerations(ctx context.Context, state string) ([]AdminAvatarModeration, error) {
+	if len(state) == 0 {
+		state = models.AvatarModerationPending
+	}
+	if !avatarModerationStates[state] {
+		return nil, InvalidAvatarState
+	}
+	rows, err := s.main.teamDB.GetAvatarModerations(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("get avatar moderations: %w", err)
+	}
+
+	out := make([]AdminAvatarModeration, len(rows))
+	for i, row := range rows {
+		out[i] = newAdminAvatarModeration(row)
+	}
+	return out, nil
+}
+
+// getPendingAvatar returns AvatarNotPending for reviewed avatar, its payload is already dropped
+func (s *AdminInternal) getPendingAvatar(ctx context.Context, moderationID int) (*models.AvatarModerationXXX, error) {
+	row, err := s.main.teamDB.GetAvatarModeration(ctx, moderationID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	if row.State != models.AvatarModerationPending {
+		return nil, AvatarNotPending
+	}
+	return row, nil
+}
+
+func (s *AdminInternal) GetPendingAvatarImage(ctx context.Context, moderationID int) ([]byte, error) {
+	row, err := s.getPendingAvatar(ctx, moderationID)
+	if err != nil {
+		return nil, err
+	}
+	return row.Avatar, nil
+}
+
+// ApproveAvatar stores avatar and makes it public, previous avatar is deleted
+func (s *AdminInternal) ApproveAvatar(ctx context.Context, moderationID int, admin string) (*AdminAvatarModeration, error) {
+	row, err := s.getPendingAvatar(ctx, moderationID)
+	if err != nil {
+		return nil, err
+	}
+	team, err := s.main.teamDB.GetByID(ctx, row.TeamID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	oldAvatar := team.AvatarPath
+	if err := team.SetAvatar(ctx, s.main.avatars, row.Avatar); err != nil {
+		return nil, fmt.Errorf("set avatar: %w", err)
+	}
+
+	// row is approved only together with new path, so pending payload is not cleared for avatar nobody sees
+	err = s.main.tx(ctx, func(ctx context.Context) error {
+		// row could be reviewed meanwhile
+		if err := s.main.teamDB.ReviewAvatarModeration(ctx, moderationID, admin, models.AvatarModerationApproved, ""); err == sql.ErrNoRows {
+			return AvatarNotPending
+		} else if err != nil {
+			return fmt.Errorf("review avatar: %w", err)
+		}
+		if err := s.main.teamDB.SetAvatarPath(ctx, team.ID, team.AvatarPath); err != nil {
+			return fmt.Errorf("set avatar path: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.main.DeleteAvatar(ctx, team.AvatarPath)
+		return nil, err
+	}
+	s.main.DeleteAvatar(ctx, oldAvatar)
+	return s.getAvatarModeration(ctx, moderationID)
+}
+
+// RejectAvatar reason is shown to team
+func (s *AdminInternal) RejectAvatar(ctx context.Context, moderationID int, admin string, reason string) (*AdminAvatarModeration, error) {
+	reason = strings.TrimSpace(reason)
+	if len(reason) == 0 || len(reason) > 255 {
+		return nil, InvalidReason
+	}
+	if _, err := s.getPendingAvatar(ctx, moderationID); err != nil {
+		return nil, err
+	}
+	if err := s.main.teamDB.ReviewAvatarModeration(ctx, moderationID, admin, models.AvatarModerationRejected, reason); err == sql.ErrNoRows {
+		return nil, AvatarNotPending
+	} else if err != nil {
+		return nil, fmt.Errorf("review avatar: %w", err)
+	}
+	return s.getAvatarModeration(ctx, moderationID)
+}
+
+func (s *AdminInternal) getAvatarModeration(ctx context.Context, moderationID int) (*AdminAvatarModeration, error) {
+	row, err := s.main.teamDB.GetAvatarModeration(ctx, moderationID)
+	if err != nil {
+		return nil, wrapAdminErr(err)
+	}
+	out := newAdminAvatarModeration(row)
+	return &out, nil
+}
@@ -0,0 +1,225 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"ctfplatform/storage"
+	"os"
+	"testing"
+)
+
+func TestSetTeamStateBan(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "banned")
+
+	sessionID, err := s.NewSession(ctx, team.ID, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.CreateToken(ctx, team.ID, "script", models.TokenScopeSubmit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateToken(ctx, token.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := admin.SetTeamState(ctx, team.ID, "root", models.TeamStateBanned, "cheating"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateSession(ctx, team.ID, sessionID, team.SessionVersion); err != SessionRevoked {
+		t.Fatalf("session of banned team: %v", err)
+	}
+	if _, err := s.AuthenticateToken(ctx, token.Token); err != TeamBanned {
+		t.Fatalf("token of banned team: %v", err)
+	}
+	moderations, err := admin.GetTeamModerations(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moderations) != 1 || moderations[0].State != models.TeamStateBanned || moderations[0].Reason != "cheating" {
+		t.Fatalf("moderations %+v", moderations)
+	}
+
+	if _, err := admin.SetTeamState(ctx, team.ID, "root", models.TeamStateActive, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticateToken(ctx, token.Token); err != nil {
+		t.Fatalf("token of unbanned team: %v", err)
+	}
+}
+
+// TestSetTeamStateRollback state is not changed when moderation log can not be written
+func TestSetTeamStateRollback(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "rollback")
+
+	if _, err := s.unsafeDB.Exec(ctx, "ALTER TABLE team_moderation RENAME TO team_moderation_off"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.SetTeamState(ctx, team.ID, "root", models.TeamStateBanned, "cheating"); err == nil {
+		t.Fatal("state set without moderation log")
+	}
+	out, err := admin.GetTeam(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.State != models.TeamStateActive {
+		t.Fatalf("state %s after failed ban", out.State)
+	}
+}
+
+func TestApproveAvatar(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	dir := t.TempDir()
+	avatars, err := storage.NewFSStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.avatars = avatars
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "avatar")
+
+	if err := s.SubmitAvatar(ctx, team.ID, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := admin.GetAvatarModerations(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("got %d pending avatars, want 1", len(pending))
+	}
+
+	// path update fails after row was reviewed, the review must be rolled back too
+	if _, err := s.unsafeDB.Exec(ctx, "CREATE TRIGGER fail_avatar BEFORE UPDATE OF avatar ON team BEGIN SELECT RAISE(ABORT, 'fail'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.ApproveAvatar(ctx, pending[0].ID, "root"); err == nil {
+		t.Fatal("approved without avatar path")
+	}
+	if _, err := admin.GetPendingAvatarImage(ctx, pending[0].ID); err != nil {
+		t.Fatalf("avatar not pending after failed approve: %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("stored avatar not deleted: %d files", len(files))
+	}
+
+	if _, err := s.unsafeDB.Exec(ctx, "DROP TRIGGER fail_avatar"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := admin.ApproveAvatar(ctx, pending[0].ID, "root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.State != models.AvatarModerationApproved {
+		t.Fatalf("state %s", out.State)
+	}
+	team, err = s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := avatars.Get(ctx, team.AvatarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "first" {
+		t.Fatalf("avatar %q", data)
+	}
+	if _, err := admin.ApproveAvatar(ctx, pending[0].ID, "root"); err != AvatarNotPending {
+		t.Fatalf("approved twice: %v", err)
+	}
+}
+
+// TestApproveAvatarKeepsCurrent db storage keeps current avatar until new one is approved and referenced
+func TestApproveAvatarKeepsCurrent(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "avatar")
+
+	if err := team.SetAvatar(ctx, s.avatars, []byte("current")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.teamDB.SetAvatarPath(ctx, team.ID, team.AvatarPath); err != nil {
+		t.Fatal(err)
+	}
+	current := team.AvatarPath
+
+	if err := s.SubmitAvatar(ctx, team.ID, []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := admin.GetAvatarModerations(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.unsafeDB.Exec(ctx, "CREATE TRIGGER fail_avatar BEFORE UPDATE OF avatar ON team BEGIN SELECT RAISE(ABORT, 'fail'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.ApproveAvatar(ctx, pending[0].ID, "root"); err == nil {
+		t.Fatal("approved without avatar path")
+	}
+	if data, err := s.avatars.Get(ctx, current); err != nil || string(data) != "current" {
+		t.Fatalf("current avatar after failed approve %q: %v", data, err)
+	}
+	var count int
+	if err := s.unsafeDB.QueryRow(ctx, "SELECT COUNT(1) FROM team_avatar WHERE team_id = ?", team.ID).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("got %d stored avatars after failed approve, want 1", count)
+	}
+
+	if _, err := s.unsafeDB.Exec(ctx, "DROP TRIGGER fail_avatar"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.ApproveAvatar(ctx, pending[0].ID, "root"); err != nil {
+		t.Fatal(err)
+	}
+	team, err = s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.avatars.Get(ctx, team.AvatarPath); err != nil || string(data) != "new" {
+		t.Fatalf("approved avatar %q: %v", data, err)
+	}
+	if _, err := s.avatars.Get(ctx, current); err != storage.ErrNotFound {
+		t.Fatalf("replaced avatar: %v", err)
+	}
+}
+
+// TestSubmitAvatarRollback pending avatar is not dropped when new one can not be stored
+func TestSubmitAvatarRollback(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	admin := NewAdmin(s)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "avatar")
+
+	if err := s.SubmitAvatar(ctx, team.ID, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.unsafeDB.Exec(ctx, "CREATE TRIGGER fail_submit BEFORE INSERT ON avatar_moderation BEGIN SELECT RAISE(ABORT, 'fail'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SubmitAvatar(ctx, team.ID, []byte("second")); err == nil {
+		t.Fatal("avatar submitted with trigger")
+	}
+
+	pending, err := admin.GetAvatarModerations(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("got %d pending avatars, want 1", len(pending))
+	}
+	if data, err := admin.GetPendingAvatarImage(ctx, pending[0].ID); err != nil || string(data) != "first" {
+		t.Fatalf("pending avatar %q: %v", data, err)
+	}
+}
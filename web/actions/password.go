@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"ctfplatform/config"
+	"ctfplatform/log"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var InvalidPasswordResetToken = errors.New("invalid password reset token")
+var InvalidCurrentPassword = errors.New("invalid current password")
+
+func genResetToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// ForgotPassword returns nil also for unknown login, caller should not distinguish these cases
+func (s *MainInternal) ForgotPassword(ctx context.Context, login string) error {
+	team, err := s.teamDB.GetByLogin(ctx, login)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	// same work in both cases
+	token, tokenHash, errToken := genResetToken()
+	if errToken != nil {
+		return errToken
+	}
+	if err == sql.ErrNoRows {
+		log.Log.WithField("email", login).Warning("team not found password reset")
+		return nil
+	}
+
+	// saved in background, request should take the same time for unknown login
+	s.goBackground(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		if err := s.teamDB.AddPasswordReset(ctx, team.ID, tokenHash, time.Now().Add(config.Config.PasswordResetTtl)); err != nil {
+			log.Log.WithError(err).WithField("team_id", team.ID).Error("add password reset err")
+			return
+		}
+
+		link := strings.TrimRight(config.Config.PublicUrl, "/") + "/reset-password?token=" + url.QueryEscape(token)
+		s.sendMail(mail.Message{
+			To:      team.Email,
+			Subject: "Password reset",
+			Body: fmt.Sprintf("Hello %s,\n\nsomeone requested a password reset for your team. To set a new password open the link below:\n\n%s\n\nThe link is valid for %s. If it was not you, just ignore this email.\n",
+				team.Name, link, config.Config.PasswordResetTtl),
+		})
+	})
+	return nil
+}
+
+// ResetPassword token is used in the same transaction as password is set, failure does not burn it
+func (s *MainInternal) ResetPassword(ctx context.Context, token string, newPassword string) (int, error) {
+	// bcrypt is slow, transaction should not wait for it
+	var team models.TeamXXX
+	if err := team.SetPassword(newPassword); err != nil {
+		return 0, fmt.Errorf("set password: %w", err)
+	}
+
+	var teamID int
+	err := s.tx(ctx, func(ctx context.Context) error {
+		var err error
+		teamID, err = s.teamDB.UsePasswordReset(ctx, hashToken(token))
+		if err == sql.ErrNoRows {
+			return InvalidPasswordResetToken
+		} else if err != nil {
+			return fmt.Errorf("use password reset: %w", err)
+		}
+		if err := s.teamDB.UpdatePassword(ctx, teamID, team.Password); err != nil {
+			return fmt.Errorf("update password: %w", err)
+		}
+		_, err = s.RevokeSessions(ctx, teamID, 0)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.sessionCache.DeleteTeam(teamID)
+	return teamID, nil
+}
+
+// ChangePassword revokes all other sessions, returns new session version for current session
+func (s *MainInternal) ChangePassword(ctx context.Context, teamID int, sessionID int, currentPassword string, newPassword string) (int, error) {
+	team, err := s.teamDB.GetByID(ctx, teamID)
+	if err != nil {
+		return 0, fmt.Errorf("get team by id: %w", err)
+	}
+	if !team.EqualPassword(currentPassword) {
+		return 0, InvalidCurrentPassword
+	}
+
+	if err := team.SetPassword(newPassword); err != nil {
+		return 0, fmt.Errorf("set password: %w", err)
+	}
+	if err := s.teamDB.UpdatePassword(ctx, teamID, team.Password); err != nil {
+		return 0, fmt.Errorf("update password: %w", err)
+	}
+	return s.RevokeSessions(ctx, teamID, sessionID)
+}
@@ -0,0 +1,98 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/mail"
+	"ctfplatform/mail/smtptest"
+	"net/url"
+	"regexp"
+	"testing"
+)
+
+var resetLinkRe = regexp.MustCompile(`/reset-password\?token=(\S+)`)
+
+func resetToken(t *testing.T, m smtptest.Mail) string {
+	t.Helper()
+	match := resetLinkRe.FindStringSubmatch(m.Data)
+	if match == nil {
+		t.Fatalf("no reset link in mail:\n%s", m.Data)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestResetPassword(t *testing.T) {
+	srv := newTestMailer(t)
+	mailer, err := mail.NewSMTPMailer(srv.Addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestMain(t, mailer)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "reset")
+
+	if err := s.ForgotPassword(ctx, team.Email); err != nil {
+		t.Fatal(err)
+	}
+	mails := waitMails(t, s, srv)
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	token := resetToken(t, mails[0])
+
+	if _, err := s.ResetPassword(ctx, "wrong", "newpassword"); err != InvalidPasswordResetToken {
+		t.Fatalf("reset with wrong token: %v", err)
+	}
+	teamID, err := s.ResetPassword(ctx, token, "newpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teamID != team.ID {
+		t.Fatalf("reset team %d, want %d", teamID, team.ID)
+	}
+	team, err = s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !team.EqualPassword("newpassword") {
+		t.Fatal("password was not changed")
+	}
+	if _, err := s.ResetPassword(ctx, token, "otherpassword"); err != InvalidPasswordResetToken {
+		t.Fatalf("token used twice: %v", err)
+	}
+}
+
+// TestResetPasswordRollback failed password update must not burn the token
+func TestResetPasswordRollback(t *testing.T) {
+	srv := newTestMailer(t)
+	mailer, err := mail.NewSMTPMailer(srv.Addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestMain(t, mailer)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "rollback")
+
+	if err := s.ForgotPassword(ctx, team.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := resetToken(t, waitMails(t, s, srv)[0])
+
+	// sessions are revoked last, without the table the transaction fails after token was used
+	if _, err := s.unsafeDB.Exec(ctx, "ALTER TABLE team_session RENAME TO team_session_off"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ResetPassword(ctx, token, "newpassword"); err == nil {
+		t.Fatal("reset succeeded without sessions table")
+	}
+	if _, err := s.unsafeDB.Exec(ctx, "ALTER TABLE team_session_off RENAME TO team_session"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ResetPassword(ctx, token, "newpassword"); err != nil {
+		t.Fatalf("token burned by failed reset: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/log"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var SessionRevoked = errors.New("session revoked")
+var SessionNotFound = errors.New("session not found")
+
+const sessionCacheMaxItems = 10000
+
+type sessionCacheKey struct {
+	teamID    int
+	sessionID int
+	version   int
+}
+
+// sessionCache remembers valid sessions for a short time, so not every request hits db.
+// Revocation made on other replica is visible after config.SessionCacheTtl.
+type sessionCache struct {
+	mu    sync.Mutex
+	items map[sessionCacheKey]time.Time
+}
+
+func newSessionCache() *sessionCache {
+	return &sessionCache{
+		items: make(map[sessionCacheKey]time.Time),
+	}
+}
+
+func (c *sessionCache) Valid(key sessionCacheKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	validUntil, exists := c.items[key]
+	return exists && time.Now().Before(validUntil)
+}
+
+// Set keeps at most sessionCacheMaxItems, when full expired entries are dropped first and then random tenth of the rest
+func (c *sessionCache) Set(key sessionCacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if _, exists := c.items[key]; !exists && len(c.items) >= sessionCacheMaxItems {
+		for k, validUntil := range c.items {
+			if now.After(validUntil) {
+				delete(c.items, k)
+			}
+		}
+		// evicted session is only checked in db again, map iteration order is random
+		for k := range c.items {
+			if len(c.items) < sessionCacheMaxItems-sessionCacheMaxItems/10 {
+				break
+			}
+			delete(c.items, k)
+		}
+	}
+	c.items[key] = now.Add(config.Config.SessionCacheTtl)
+}
+
+func (c *sessionCache) DeleteTeam(teamID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.items {
+		if k.teamID == teamID {
+			delete(c.items, k)
+		}
+	}
+}
+
+func (s *MainInternal) NewSession(ctx context.Context, teamID int, userIP string, userAgent string) (int, error) {
+	sessionID, err := s.teamDB.AddSession(ctx, teamID, userIP, userAgent)
+	if err != nil {
+		return 0, fmt.Errorf("add session: %w", err)
+	}
+	return sessionID, nil
+}
+
+// ValidateSession checks if session was not revoked, sessionID is 0 for old sessions created before sessions table
+func (s *MainInternal) ValidateSession(ctx context.Context, teamID int, sessionID int, version int) error {
+	key := sessionCacheKey{teamID: teamID, sessionID: sessionID, version: version}
+	if s.sessionCache.Valid(key) {
+		return nil
+	}
+
+	if sessionID == 0 {
+		currentVersion, err := s.teamDB.GetSessionVersion(ctx, teamID)
+		if err == sql.ErrNoRows {
+			return SessionRevoked
+		} else if err != nil {
+			return fmt.Errorf("get session version: %w", err)
+		}
+		if currentVersion != version {
+			return SessionRevoked
+		}
+		s.sessionCache.Set(key)
+		return nil
+	}
+
+	sessionData, err := s.teamDB.GetSession(ctx, teamID, sessionID)
+	if err == sql.ErrNoRows {
+		return SessionRevoked
+	} else if err != nil {
+		return fmt.Errorf("get session: %w", err)
+	}
+	if sessionData.Revoked || sessionData.TeamSessionVersion != version {
+		return SessionRevoked
+	}
+	if time.Since(sessionData.LastSeenAt) > time.Minute {
+		if err := s.teamDB.TouchSession(ctx, sessionID); err != nil {
+			log.Log.WithError(err).WithField("session_id", sessionID).Warning("touch session err")
+		}
+	}
+	s.sessionCache.Set(key)
+	return nil
+}
+
+type TeamSession struct {
+	ID         int       `json:"id"`
+	UserIP     string    `json:"user_ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+func (s *MainInternal) GetSessions(ctx context.Context, teamID int, currentSessionID int) ([]TeamSession, error) {
+	rows, err := s.teamDB.GetSessions(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get sessions: %w", err)
+	}
+
+	out := make([]TeamSession, len(rows))
+	for i, row := range rows {
+		out[i] = TeamSession{
+			ID:         row.ID,
+			UserIP:     row.UserIP,
+			UserAgent:  row.UserAgent,
+			CreatedAt:  row.CreatedAt,
+			LastSeenAt: row.LastSeenAt,
+			Current:    row.ID == currentSessionID,
+		}
+	}
+	return out, nil
+}
+
+func (s *MainInternal) RevokeSession(ctx context.Context, teamID int, sessionID int) error {
+	err := s.teamDB.RevokeSession(ctx, teamID, sessionID)
+	if err == sql.ErrNoRows {
+		return SessionNotFound
+	} else if err != nil {
+		return fmt.Errorf("revoke session: %w", err)
+	}
+	s.sessionCache.DeleteTeam(teamID)
+	return nil
+}
+
+// RevokeSessions revokes all team sessions except exceptSessionID (0 revokes all),
+// returns new session version which should be set in the kept session.
+// Caller running it in transaction should drop team from sessionCache again after commit,
+// until then other requests still read the old version.
+func (s *MainInternal) RevokeSessions(ctx context.Context, teamID int, exceptSessionID int) (int, error) {
+	version, err := s.teamDB.RevokeSessions(ctx, teamID, exceptSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("revoke sessions: %w", err)
+	}
+	s.sessionCache.DeleteTeam(teamID)
+	return version, nil
+}
@@ -0,0 +1,61 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/mail"
+	"testing"
+	"time"
+)
+
+func TestSessionCacheCap(t *testing.T) {
+	defer func(v time.Duration) { config.Config.SessionCacheTtl = v }(config.Config.SessionCacheTtl)
+	config.Config.SessionCacheTtl = time.Minute
+
+	c := newSessionCache()
+	for i := 0; i < 3*sessionCacheMaxItems; i++ {
+		c.Set(sessionCacheKey{teamID: i, sessionID: i, version: 1})
+		if len(c.items) > sessionCacheMaxItems {
+			t.Fatalf("cache has %d items after %d sets", len(c.items), i+1)
+		}
+	}
+	last := sessionCacheKey{teamID: 3*sessionCacheMaxItems - 1, sessionID: 3*sessionCacheMaxItems - 1, version: 1}
+	if !c.Valid(last) {
+		t.Fatal("last set session is not cached")
+	}
+}
+
+func TestRevokeSessions(t *testing.T) {
+	s := newTestMain(t, mail.LogMailer{})
+	ctx := context.Background()
+	team := addTestTeam(t, s, "revoke")
+
+	kept, err := s.NewSession(ctx, team.ID, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.NewSession(ctx, team.ID, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ValidateSession(ctx, team.ID, other, team.SessionVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := s.RevokeSessions(ctx, team.ID, kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != team.SessionVersion+1 {
+		t.Fatalf("version %d, want %d", version, team.SessionVersion+1)
+	}
+	if err := s.ValidateSession(ctx, team.ID, kept, version); err != nil {
+		t.Fatalf("kept session: %v", err)
+	}
+	if err := s.ValidateSession(ctx, team.ID, other, team.SessionVersion); err != SessionRevoked {
+		t.Fatalf("revoked session with old version: %v", err)
+	}
+	if err := s.ValidateSession(ctx, team.ID, other, version); err != SessionRevoked {
+		t.Fatalf("revoked session with new version: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package actions
+
+import (
+	"context"
+	"crypto/rand"
+	"ctfplatform/log"
+	"ctfplatform/models"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const tokenPrefix = "ctf_"
+const maxTokensPerTeam = 20
+
+var TokenNotFound = errors.New("token not found")
+var InvalidToken = errors.New("invalid token")
+var InvalidTokenScope = errors.New("invalid token scope")
+var TooManyTokens = errors.New("too many tokens")
+
+type TeamToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	Token      string     `json:"token,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// CreateToken returns plain token only once, db keeps only its hash
+func (s *MainInternal) CreateToken(ctx context.Context, teamID int, name string, scope string) (*TeamToken, error) {
+	if scope != models.TokenScopeRead && scope != models.TokenScopeSubmit {
+		return nil, InvalidTokenScope
+	}
+
+	tokens, err := s.teamDB.GetTokens(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get tokens: %w", err)
+	}
+	if len(tokens) >= maxTokensPerTeam {
+		return nil, TooManyTokens
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	tokenID, err := s.teamDB.AddToken(ctx, teamID, name, scope, hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("add token: %w", err)
+	}
+	return &TeamToken{
+		ID:        tokenID,
+		Name:      name,
+		Scope:     scope,
+		Token:     token,
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+func (s *MainInternal) GetTokens(ctx context.Context, teamID int) ([]TeamToken, error) {
+	rows, err := s.teamDB.GetTokens(ctx, teamID)
+	if err != nil {
+		return nil, fmt.Errorf("get tokens: %w", err)
+	}
+
+	out := make([]TeamToken, len(rows))
+	for i, row := range rows {
+		out[i] = TeamToken{
+			ID:         row.ID,
+			Name:       row.Name,
+			Scope:      row.Scope,
+			CreatedAt:  row.CreatedAt,
+			LastUsedAt: row.LastUsedAt,
+		}
+	}
+	return out, nil
+}
+
+func (s *MainInternal) RevokeToken(ctx context.Context, teamID int, tokenID int) error {
+	err := s.teamDB.RevokeToken(ctx, teamID, tokenID)
+	if err == sql.ErrNoRows {
+		return TokenNotFound
+	} else if err != nil {
+		return fmt.Errorf("revoke token: %w", err)
+	}
+	return nil
+}
+
+func (s *MainInternal) AuthenticateToken(ctx context.Context, token string) (*models.TeamTokenXXX, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, InvalidToken
+	}
+
+	tokenData, err := s.teamDB.GetTokenByHash(ctx, hashToken(token))
+	if err == sql.ErrNoRows {
+		return nil, InvalidToken
+	} else if err != nil {
+		return nil, fmt.Errorf("get token: %w", err)
+	}
+
+	// tokens are not revoked by ban, they work again when team is unbanned
+	team, err := s.teamDB.GetByID(ctx, tokenData.TeamID)
+	if err == sql.ErrNoRows {
+		return nil, InvalidToken
+	} else if err != nil {
+		return nil, fmt.Errorf("get team: %w", err)
+	}
+	if err := CheckTeamCanLogin(team); err != nil {
+		return nil, err
+	}
+
+	// scripts can submit a lot, last used is good enough with minute precision
+	if tokenData.LastUsedAt == nil || time.Since(*tokenData.LastUsedAt) > time.Minute {
+		if err := s.teamDB.TouchToken(ctx, tokenData.ID); err != nil {
+			log.Log.WithError(err).WithField("token_id", tokenData.ID).Warning("touch token err")
+		}
+	}
+	return tokenData, nil
+}
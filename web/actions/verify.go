@@ -0,0 +1,104 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/log"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"ctfplatform/session"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var InvalidVerificationToken = errors.New("invalid verification token")
+
+const tokenPurposeVerifyEmail = "verify_email"
+
+type emailVerificationToken struct {
+	Purpose   string `json:"purpose"`
+	TeamID    int    `json:"team_id"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// sendMail does not block request, smtp server can be slow
+func (s *MainInternal) sendMail(msg mail.Message) {
+	if s.mailer == nil {
+		log.Log.WithField("email", msg.To).Warning("mailer not configured, mail dropped")
+		return
+	}
+	s.goBackground(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+		defer cancel()
+		if err := s.mailer.Send(ctx, msg); err != nil {
+			log.Log.WithError(err).WithField("email", msg.To).Error("send mail err")
+		}
+	})
+}
+
+func (s *MainInternal) SendVerificationEmail(team models.TeamXXX) error {
+	token, err := session.MarshalToken(config.Config.HmacSecretKey, emailVerificationToken{
+		Purpose:   tokenPurposeVerifyEmail,
+		TeamID:    team.ID,
+		Email:     team.Email,
+		ExpiresAt: time.Now().Add(config.Config.EmailVerificationTtl).Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal verification token: %w", err)
+	}
+
+	link := strings.TrimRight(config.Config.PublicUrl, "/") + "/api/v1/team/verify?token=" + url.QueryEscape(string(token))
+	s.sendMail(mail.Message{
+		To:      team.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nplease confirm your email address by opening the link below:\n\n%s\n\nThe link is valid for %s.\n",
+			team.Name, link, config.Config.EmailVerificationTtl),
+	})
+	return nil
+}
+
+func (s *MainInternal) ResendVerificationEmail(ctx context.Context, email string) error {
+	team, err := s.teamDB.GetByLogin(ctx, email)
+	if err == sql.ErrNoRows {
+		return TeamNotFound
+	} else if err != nil {
+		return err
+	}
+	if team.Active {
+		return nil
+	}
+	return s.SendVerificationEmail(*team)
+}
+
+func (s *MainInternal) VerifyEmail(ctx context.Context, token string) (int, error) {
+	var data emailVerificationToken
+	if err := session.UnmarshalToken(config.Config.HmacSecretKey, []byte(token), &data); err != nil {
+		return 0, InvalidVerificationToken
+	}
+	if data.Purpose != tokenPurposeVerifyEmail || time.Now().Unix() > data.ExpiresAt {
+		return 0, InvalidVerificationToken
+	}
+
+	team, err := s.teamDB.GetByID(ctx, data.TeamID)
+	if err == sql.ErrNoRows {
+		return 0, InvalidVerificationToken
+	} else if err != nil {
+		return 0, fmt.Errorf("get team by id: %w", err)
+	}
+	if team.Email != data.Email {
+		return 0, InvalidVerificationToken
+	}
+	if team.Active {
+		return team.ID, nil
+	}
+
+	if err := s.teamDB.SetActive(ctx, team.ID, true); err != nil {
+		return 0, fmt.Errorf("set team active: %w", err)
+	}
+	return team.ID, nil
+}
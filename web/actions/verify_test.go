@@ -0,0 +1,152 @@
+package actions
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/mail"
+	"ctfplatform/mail/smtptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var verifyLinkRe = regexp.MustCompile(`/api/v1/team/verify\?token=(\S+)`)
+
+// waitMails waits for background mails sent by service
+func waitMails(t *testing.T, s *MainInternal, srv *smtptest.Server) []smtptest.Mail {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return srv.Mails()
+}
+
+func verifyToken(t *testing.T, m smtptest.Mail) string {
+	t.Helper()
+	match := verifyLinkRe.FindStringSubmatch(m.Data)
+	if match == nil {
+		t.Fatalf("no verify link in mail:\n%s", m.Data)
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newTestMailer(t *testing.T) *smtptest.Server {
+	t.Helper()
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestEmailVerification(t *testing.T) {
+	defer func(v bool) { config.Config.RequireEmailVerification = v }(config.Config.RequireEmailVerification)
+	config.Config.RequireEmailVerification = true
+
+	srv := newTestMailer(t)
+	mailer, err := mail.NewSMTPMailer(srv.Addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestMain(t, mailer)
+	ctx := context.Background()
+
+	team := addTestTeam(t, s, "team1")
+	if team.Active {
+		t.Fatal("team is active before verification")
+	}
+	mails := waitMails(t, s, srv)
+	if len(mails) != 1 || mails[0].To[0] != "team1@example.com" {
+		t.Fatalf("verification mail not sent: %+v", mails)
+	}
+	token := verifyToken(t, mails[0])
+
+	if _, err := s.VerifyEmail(ctx, token+"x"); err != InvalidVerificationToken {
+		t.Fatalf("tampered token: %v", err)
+	}
+	teamID, err := s.VerifyEmail(ctx, token)
+	if err != nil || teamID != team.ID {
+		t.Fatalf("verify: %d %v", teamID, err)
+	}
+	team, err = s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !team.Active {
+		t.Fatal("team not active after verification")
+	}
+
+	// link can be opened again
+	if _, err := s.VerifyEmail(ctx, token); err != nil {
+		t.Fatalf("second verify: %v", err)
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	defer func(v bool) { config.Config.RequireEmailVerification = v }(config.Config.RequireEmailVerification)
+	config.Config.RequireEmailVerification = true
+
+	srv := newTestMailer(t)
+	mailer, err := mail.NewSMTPMailer(srv.Addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestMain(t, mailer)
+	ctx := context.Background()
+
+	addTestTeam(t, s, "team1")
+	if err := s.ResendVerificationEmail(ctx, "team1@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	mails := waitMails(t, s, srv)
+	if len(mails) != 2 {
+		t.Fatalf("got %d mails, want registration and resent one", len(mails))
+	}
+	if _, err := s.VerifyEmail(ctx, verifyToken(t, mails[1])); err != nil {
+		t.Fatalf("verify resent token: %v", err)
+	}
+
+	// active team gets no more mails
+	if err := s.ResendVerificationEmail(ctx, "team1@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if mails := waitMails(t, s, srv); len(mails) != 2 {
+		t.Fatalf("mail sent to active team, got %d mails", len(mails))
+	}
+	if err := s.ResendVerificationEmail(ctx, "nobody@example.com"); err != TeamNotFound {
+		t.Fatalf("unknown email: %v", err)
+	}
+}
+
+func TestEmailVerificationExpired(t *testing.T) {
+	defer func(v bool, ttl time.Duration) {
+		config.Config.RequireEmailVerification = v
+		config.Config.EmailVerificationTtl = ttl
+	}(config.Config.RequireEmailVerification, config.Config.EmailVerificationTtl)
+	config.Config.RequireEmailVerification = true
+	config.Config.EmailVerificationTtl = -time.Minute
+
+	srv := newTestMailer(t)
+	mailer, err := mail.NewSMTPMailer(srv.Addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestMain(t, mailer)
+
+	addTestTeam(t, s, "team1")
+	mails := waitMails(t, s, srv)
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails", len(mails))
+	}
+	if _, err := s.VerifyEmail(context.Background(), verifyToken(t, mails[0])); err != InvalidVerificationToken {
+		t.Fatalf("expired token: %v", err)
+	}
+}
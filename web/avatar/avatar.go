@@ -0,0 +1,66 @@
+// Package avatar turns uploaded images into canonical square PNGs, user bytes are never stored
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+)
+
+var ErrInvalid = errors.New("invalid avatar")
+
+// other decoders can be registered by any imported package, so formats are listed explicitly
+var formats = map[string]bool{
+	"png":  true,
+	"jpeg": true,
+	"webp": true,
+}
+
+const (
+	minSide = 30
+	// checked before decoding, so big dimensions in small file do not allocate gigabytes
+	maxPixels = 4096 * 4096
+)
+
+// Normalize decodes png, jpeg or webp, crops center square and scales it to size x size png
+func Normalize(payload []byte, size int) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", err, ErrInvalid)
+	}
+	if !formats[format] {
+		return nil, fmt.Errorf("%w: format %s not allowed", ErrInvalid, format)
+	}
+	if cfg.Width < minSide || cfg.Height < minSide {
+		return nil, fmt.Errorf("%w: too small w=%d h=%d", ErrInvalid, cfg.Width, cfg.Height)
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("%w: too big w=%d h=%d", ErrInvalid, cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %v: %w", format, err, ErrInvalid)
+	}
+
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(bounds.Min).Add(image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2))
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, dst); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
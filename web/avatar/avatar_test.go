@@ -0,0 +1,140 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+// stripes is w x h image with thirds in red, green and blue along its longer side
+func stripes(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pos, long := x, w
+			if h > w {
+				pos, long = y, h
+			}
+			c := color.NRGBA{R: 0xff, A: 0xff}
+			if pos >= 2*long/3 {
+				c = color.NRGBA{B: 0xff, A: 0xff}
+			} else if pos >= long/3 {
+				c = color.NRGBA{G: 0xff, A: 0xff}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// hugeHeaderPNG is valid small png whose header claims w x h, decoding it would allocate w*h pixels
+func hugeHeaderPNG(t *testing.T, w, h uint32) []byte {
+	t.Helper()
+	payload := encodePNG(t, image.NewGray(image.Rect(0, 0, 1, 1)))
+	// 8 bytes signature, 4 bytes length, then "IHDR" with width and height first
+	ihdr := payload[12 : 12+4+13]
+	binary.BigEndian.PutUint32(ihdr[4:], w)
+	binary.BigEndian.PutUint32(ihdr[8:], h)
+	binary.BigEndian.PutUint32(payload[12+4+13:], crc32.ChecksumIEEE(ihdr))
+	return payload
+}
+
+func TestNormalize(t *testing.T) {
+	webp, err := os.ReadFile("testdata/gopher.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	huge := hugeHeaderPNG(t, 5000, 5000)
+	if len(huge) > 1024 {
+		t.Fatalf("huge header png has %d bytes", len(huge))
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		// part of error message, empty when avatar is accepted
+		wantErr string
+	}{
+		{"png", encodePNG(t, stripes(64, 64)), ""},
+		{"jpeg", encodeJPEG(t, stripes(64, 48)), ""},
+		{"webp", webp, ""},
+		{"gif not allowed", encodeGIF(t, stripes(64, 64)), "format gif not allowed"},
+		{"too small", encodePNG(t, stripes(64, 20)), "too small"},
+		{"huge header", huge, "too big"},
+		{"not image", []byte("<svg></svg>"), "unknown format"},
+	}
+	for _, tt := range tests {
+		out, err := Normalize(tt.payload, 32)
+		if len(tt.wantErr) > 0 {
+			if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: err %v, want ErrInvalid with %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+		if err != nil || format != "png" || cfg.Width != 32 || cfg.Height != 32 {
+			t.Errorf("%s: output %s %dx%d %v", tt.name, format, cfg.Width, cfg.Height, err)
+		}
+	}
+}
+
+func TestNormalizeCropsCenter(t *testing.T) {
+	for _, size := range []image.Point{{90, 30}, {30, 90}} {
+		out, err := Normalize(encodePNG(t, stripes(size.X, size.Y)), 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// only center square is kept, it is green everywhere
+		for _, p := range []image.Point{{0, 0}, {31, 0}, {0, 31}, {31, 31}, {16, 16}} {
+			r, g, b, _ := img.At(p.X, p.Y).RGBA()
+			if r>>8 > 0x10 || g>>8 < 0xf0 || b>>8 > 0x10 {
+				t.Errorf("%dx%d: pixel %v is %02x%02x%02x, want green", size.X, size.Y, p, r>>8, g>>8, b>>8)
+			}
+		}
+	}
+}
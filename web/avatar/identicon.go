@@ -0,0 +1,105 @@
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+const (
+	identiconGrid   = 5
+	identiconPrefix = "identicon-"
+)
+
+var identiconBackground = color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+// IdenticonPath is avatar file name of generated avatar for team
+func IdenticonPath(teamID int) string {
+	return fmt.Sprintf("%s%d.png", identiconPrefix, teamID)
+}
+
+// ParseIdenticonPath returns team id when path is generated avatar
+func ParseIdenticonPath(path string) (int, bool) {
+	if !strings.HasPrefix(path, identiconPrefix) || !strings.HasSuffix(path, ".png") {
+		return 0, false
+	}
+	teamID, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(path, identiconPrefix), ".png"))
+	if err != nil || teamID <= 0 {
+		return 0, false
+	}
+	return teamID, true
+}
+
+// Identicon renders horizontally symmetric 5x5 pattern, the same team id gives the same image
+func Identicon(teamID int, size int) ([]byte, error) {
+	sum := sha256.Sum256([]byte("team:" + strconv.Itoa(teamID)))
+
+	// hue from hash, saturation and lightness fixed so every avatar is readable on light background
+	hue := float64(uint16(sum[0])<<8|uint16(sum[1])) / 65536
+	fg := hslToRGB(hue, 0.55, 0.55)
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{identiconBackground, fg})
+	cell := size / (identiconGrid + 1)
+	margin := (size - cell*identiconGrid) / 2
+
+	bit := 0
+	for x := 0; x < (identiconGrid+1)/2; x++ {
+		for y := 0; y < identiconGrid; y++ {
+			on := sum[2+bit/8]&(1<<(bit%8)) != 0
+			bit++
+			if !on {
+				continue
+			}
+			for _, col := range []int{x, identiconGrid - 1 - x} {
+				rect := image.Rect(margin+col*cell, margin+y*cell, margin+(col+1)*cell, margin+(y+1)*cell)
+				for py := rect.Min.Y; py < rect.Max.Y; py++ {
+					for px := rect.Min.X; px < rect.Max.X; px++ {
+						img.SetColorIndex(px, py, 1)
+					}
+				}
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func hslToRGB(h, s, l float64) color.RGBA {
+	var q float64
+	if l < 0.5 {
+		q = l * (1 + s)
+	} else {
+		q = l + s - l*s
+	}
+	p := 2*l - q
+	channel := func(t float64) uint8 {
+		if t < 0 {
+			t++
+		}
+		if t > 1 {
+			t--
+		}
+		var v float64
+		switch {
+		case t < 1.0/6:
+			v = p + (q-p)*6*t
+		case t < 1.0/2:
+			v = q
+		case t < 2.0/3:
+			v = p + (q-p)*(2.0/3-t)*6
+		default:
+			v = p
+		}
+		return uint8(v*255 + 0.5)
+	}
+	return color.RGBA{R: channel(h + 1.0/3), G: channel(h), B: channel(h - 1.0/3), A: 0xff}
+}
@@ -0,0 +1,57 @@
+package avatar
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestIdenticonDeterministic(t *testing.T) {
+	first, err := Identicon(42, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Identicon(42, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatal("same team id gives different images")
+	}
+
+	other, err := Identicon(43, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, other) {
+		t.Fatal("different team ids give the same image")
+	}
+
+	img, err := png.Decode(bytes.NewReader(first))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 120, 120) {
+		t.Fatalf("bounds %v", img.Bounds())
+	}
+	// pattern is mirrored horizontally
+	for y := 0; y < 120; y++ {
+		for x := 0; x < 60; x++ {
+			if img.At(x, y) != img.At(119-x, y) {
+				t.Fatalf("pixel %d,%d differs from its mirror", x, y)
+			}
+		}
+	}
+}
+
+func TestIdenticonPath(t *testing.T) {
+	if teamID, ok := ParseIdenticonPath(IdenticonPath(7)); !ok || teamID != 7 {
+		t.Fatalf("parse own path %d %v", teamID, ok)
+	}
+	for _, path := range []string{"7.png", "identicon-0.png", "identicon--1.png", "identicon-7.jpg", "identicon-x.png", "identicon-7.png/.."} {
+		if teamID, ok := ParseIdenticonPath(path); ok {
+			t.Errorf("%q parsed as team %d", path, teamID)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalid token was checked and rejected
+var ErrInvalid = errors.New("captcha invalid")
+
+// ErrUnavailable token could not be checked (timeout, provider error), caller decides if it fails open or closed
+var ErrUnavailable = errors.New("captcha provider unavailable")
+
+const (
+	ProviderRecaptcha = "recaptcha"
+	ProviderHCaptcha  = "hcaptcha"
+	ProviderTurnstile = "turnstile"
+	ProviderTest      = "test"
+	ProviderDisabled  = "disabled"
+)
+
+var verifyURLs = map[string]string{
+	ProviderRecaptcha: "https://www.google.com/recaptcha/api/siteverify",
+	ProviderHCaptcha:  "https://api.hcaptcha.com/siteverify",
+	ProviderTurnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+}
+
+type Verifier interface {
+	// Verify returns ErrInvalid or error wrapping ErrUnavailable
+	Verify(ctx context.Context, token string, remoteIP string) error
+}
+
+// SiteVerify is shared by recaptcha, hcaptcha and turnstile, they have the same siteverify api
+type SiteVerify struct {
+	url      string
+	secret   string
+	minScore float64
+	client   *http.Client
+}
+
+// NewSiteVerify minScore is checked only when provider returns score (recaptcha v3), 0 disables it
+func NewSiteVerify(verifyURL string, secret string, minScore float64) *SiteVerify {
+	return &SiteVerify{
+		url:      verifyURL,
+		secret:   secret,
+		minScore: minScore,
+		client:   http.DefaultClient,
+	}
+}
+
+func (v *SiteVerify) Verify(ctx context.Context, token string, remoteIP string) error {
+	if len(token) == 0 {
+		return ErrInvalid
+	}
+
+	formData := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if len(remoteIP) > 0 {
+		formData.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(formData.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var result struct {
+		Success    bool     `json:"success"`
+		Score      *float64 `json:"score"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	if !result.Success {
+		for _, code := range result.ErrorCodes {
+			// our configuration is broken, not the token
+			if code == "missing-input-secret" || code == "invalid-input-secret" {
+				return fmt.Errorf("%w: %s", ErrUnavailable, code)
+			}
+		}
+		return ErrInvalid
+	}
+	if result.Score != nil && *result.Score < v.minScore {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Static accepts only one token, for e2e tests where frontend sends known token
+type Static struct {
+	token string
+}
+
+func (v Static) Verify(ctx context.Context, token string, remoteIP string) error {
+	if len(v.token) == 0 || token != v.token {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// Disabled accepts everything
+type Disabled struct{}
+
+func (v Disabled) Verify(ctx context.Context, token string, remoteIP string) error {
+	return nil
+}
+
+// NewVerifier verifyURL overrides provider siteverify url, e.g. local stand-in from captchatest
+func NewVerifier(provider string, secret string, verifyURL string, minScore float64) (Verifier, error) {
+	switch provider {
+	case ProviderRecaptcha, ProviderHCaptcha, ProviderTurnstile:
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("captcha provider %s requires secret", provider)
+		}
+		if len(verifyURL) == 0 {
+			verifyURL = verifyURLs[provider]
+		}
+		return NewSiteVerify(verifyURL, secret, minScore), nil
+	case ProviderTest:
+		if len(secret) == 0 {
+			return nil, errors.New("captcha provider test requires secret, it is the only accepted token")
+		}
+		return Static{token: secret}, nil
+	case ProviderDisabled:
+		return Disabled{}, nil
+	}
+	return nil, fmt.Errorf("unknown captcha provider: %s", provider)
+}
@@ -0,0 +1,62 @@
+package captcha
+
+import (
+	"context"
+	"ctfplatform/captcha/captchatest"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSiteVerify(t *testing.T) {
+	srv := captchatest.NewServer("secret")
+	defer srv.Close()
+	srv.Allow("ok")
+	srv.AllowWithScore("human", 0.9)
+	srv.AllowWithScore("bot", 0.1)
+
+	v := NewSiteVerify(srv.URL, "secret", 0.5)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, tc := range []struct {
+		token string
+		want  error
+	}{
+		{"ok", nil},
+		{"human", nil},
+		{"bot", ErrInvalid},
+		{"unknown", ErrInvalid},
+		{"", ErrInvalid},
+	} {
+		if err := v.Verify(ctx, tc.token, "127.0.0.1"); err != tc.want {
+			t.Errorf("token %q: got %v, want %v", tc.token, err, tc.want)
+		}
+	}
+
+	// broken configuration is not user's fault
+	if err := NewSiteVerify(srv.URL, "wrong", 0.5).Verify(ctx, "ok", ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("wrong secret: %v", err)
+	}
+}
+
+func TestSiteVerifyUnavailable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := NewSiteVerify(failing.URL, "secret", 0).Verify(ctx, "ok", ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("provider error: %v", err)
+	}
+
+	srv := captchatest.NewServer("secret")
+	srv.Close()
+	if err := NewSiteVerify(srv.URL, "secret", 0).Verify(ctx, "ok", ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("closed provider: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package captchatest is local stand-in for siteverify api of recaptcha, hcaptcha and turnstile
+package captchatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// Server accepts tokens added with Allow, use its URL as CAPTCHA_VERIFY_URL or in captcha.NewSiteVerify
+type Server struct {
+	*httptest.Server
+
+	secret string
+	mu     sync.Mutex
+	tokens map[string]*float64
+}
+
+func NewServer(secret string) *Server {
+	s := &Server{
+		secret: secret,
+		tokens: make(map[string]*float64),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Allow token is accepted without score (hcaptcha, turnstile, recaptcha v2)
+func (s *Server) Allow(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = nil
+}
+
+// AllowWithScore token is accepted with recaptcha v3 score
+func (s *Server) AllowWithScore(token string, score float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = &score
+}
+
+type response struct {
+	Success    bool     `json:"success"`
+	Score      *float64 `json:"score,omitempty"`
+	ErrorCodes []string `json:"error-codes,omitempty"`
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	out := response{}
+	if r.PostForm.Get("secret") != s.secret {
+		out.ErrorCodes = []string{"invalid-input-secret"}
+	} else {
+		s.mu.Lock()
+		score, exists := s.tokens[r.PostForm.Get("response")]
+		s.mu.Unlock()
+		if exists {
+			out.Success = true
+			out.Score = score
+		} else {
+			out.ErrorCodes = []string{"invalid-input-response"}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,186 @@
+package challenge
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FileName is looked up in every directory of imported tree
+const FileName = "challenge.yml"
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Challenge is single challenge.yml, slug defaults to directory name
+type Challenge struct {
+	Path string `yaml:"-"`
+
+	Slug        string   `yaml:"slug"`
+	Name        string   `yaml:"name"`
+	Category    string   `yaml:"category"`
+	Difficulty  string   `yaml:"difficulty"`
+	Description string   `yaml:"description"`
+	Flags       []string `yaml:"flags"`
+	Files       []string `yaml:"files"`
+	// RFC3339, task is hidden until released, empty keeps it hidden
+	Release string `yaml:"release"`
+
+	ReleaseAt *time.Time `yaml:"-"`
+}
+
+type FieldError struct {
+	File  string
+	Field string
+	Msg   string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.File, e.Field, e.Msg)
+}
+
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Load reads all challenge.yml files under root, it returns Errors with every invalid field found
+func Load(root string) ([]*Challenge, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && info.Name() == FileName {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var errs Errors
+	out := make([]*Challenge, 0, len(paths))
+	slugs := make(map[string]string)
+	flags := make(map[string]string)
+	for _, path := range paths {
+		c, fieldErrs := loadFile(path)
+		if len(fieldErrs) > 0 {
+			errs = append(errs, fieldErrs...)
+			continue
+		}
+
+		if other, exists := slugs[c.Slug]; exists {
+			errs = append(errs, &FieldError{File: path, Field: "slug", Msg: fmt.Sprintf("%q already used in %s", c.Slug, other)})
+			continue
+		}
+		slugs[c.Slug] = path
+
+		duplicated := false
+		for i, flag := range c.Flags {
+			if other, exists := flags[flag]; exists {
+				errs = append(errs, &FieldError{File: path, Field: fmt.Sprintf("flags[%d]", i), Msg: "flag already used in " + other})
+				duplicated = true
+			}
+			flags[flag] = path
+		}
+		if duplicated {
+			continue
+		}
+		out = append(out, c)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return out, nil
+}
+
+func loadFile(path string) (*Challenge, Errors) {
+	payload, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, Errors{err}
+	}
+
+	var c Challenge
+	if err := yaml.UnmarshalStrict(payload, &c); err != nil {
+		return nil, Errors{&FieldError{File: path, Field: "yaml", Msg: strings.TrimPrefix(err.Error(), "yaml: ")}}
+	}
+	c.Path = path
+	if len(c.Slug) == 0 {
+		c.Slug = filepath.Base(filepath.Dir(path))
+	}
+	return &c, c.validate()
+}
+
+func (c *Challenge) validate() Errors {
+	var errs Errors
+	fieldErr := func(field string, msg string) {
+		errs = append(errs, &FieldError{File: c.Path, Field: field, Msg: msg})
+	}
+
+	c.Name = strings.TrimSpace(c.Name)
+	c.Category = strings.TrimSpace(c.Category)
+	c.Difficulty = strings.TrimSpace(c.Difficulty)
+
+	if !slugRegexp.MatchString(c.Slug) {
+		fieldErr("slug", fmt.Sprintf("%q should match %s", c.Slug, slugRegexp.String()))
+	}
+	if len(c.Name) == 0 || len(c.Name) > 255 {
+		fieldErr("name", "should have 1-255 characters")
+	}
+	if len(c.Category) == 0 || len(c.Category) > 128 {
+		fieldErr("category", "should have 1-128 characters")
+	}
+	if len(c.Difficulty) == 0 || len(c.Difficulty) > 32 {
+		fieldErr("difficulty", "should have 1-32 characters")
+	}
+	if len(c.Flags) == 0 {
+		fieldErr("flags", "at least one flag is required")
+	}
+	seen := make(map[string]bool, len(c.Flags))
+	for i, flag := range c.Flags {
+		field := fmt.Sprintf("flags[%d]", i)
+		if len(flag) == 0 || len(flag) > 255 {
+			fieldErr(field, "should have 1-255 characters")
+		} else if strings.TrimSpace(flag) != flag {
+			fieldErr(field, "has leading or trailing whitespace")
+		} else if seen[flag] {
+			fieldErr(field, "duplicated flag")
+		}
+		seen[flag] = true
+	}
+	for i, file := range c.Files {
+		field := fmt.Sprintf("files[%d]", i)
+		if filepath.IsAbs(file) || strings.HasPrefix(filepath.Clean(file), "..") {
+			fieldErr(field, "should be relative to challenge directory")
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(filepath.Dir(c.Path), file)); err != nil {
+			fieldErr(field, err.Error())
+		}
+	}
+	if len(c.Release) > 0 {
+		releaseAt, err := time.Parse(time.RFC3339, c.Release)
+		if err != nil {
+			fieldErr("release", "should be RFC3339 time, e.g. 2019-12-20T20:00:00Z")
+		} else {
+			c.ReleaseAt = &releaseAt
+		}
+	}
+	return errs
+}
@@ -0,0 +1,200 @@
+package challenge
+
+import (
+	"context"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+)
+
+type FieldChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// Change is difference between one challenge.yml and task in db
+type Change struct {
+	Challenge *Challenge
+	Action    string
+	TaskID    int
+
+	Fields      []FieldChange
+	FlagsAdd    []string
+	FlagsRemove []*models.TaskFlagXXX
+}
+
+// Plan compares challenges with tasks in db by slug, tasks missing in challenges are not touched
+func Plan(ctx context.Context, taskDB *models.TaskInternal, challenges []*Challenge) ([]*Change, error) {
+	out := make([]*Change, 0, len(challenges))
+	for _, c := range challenges {
+		change := &Change{
+			Challenge: c,
+			Action:    ActionUnchanged,
+		}
+
+		task, err := taskDB.GetBySlug(ctx, c.Slug)
+		if err == sql.ErrNoRows {
+			change.Action = ActionCreate
+			change.FlagsAdd = c.Flags
+			out = append(out, change)
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("get task %q: %w", c.Slug, err)
+		}
+		change.TaskID = task.ID
+
+		change.diff("name", task.Name, c.Name)
+		change.diff("category", task.Category, c.Category)
+		change.diff("difficult", task.Difficult, c.Difficulty)
+		change.diff("description", task.Description, c.Description)
+		change.diff("started_at", formatTime(task.StartedAt), formatTime(c.ReleaseAt))
+
+		flags, err := taskDB.GetTaskFlags(ctx, task.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get task %q flags: %w", c.Slug, err)
+		}
+		current := make(map[string]bool, len(flags))
+		wanted := make(map[string]bool, len(c.Flags))
+		for _, flag := range c.Flags {
+			wanted[flag] = true
+		}
+		for _, flag := range flags {
+			current[flag.Flag] = true
+			if !wanted[flag.Flag] {
+				change.FlagsRemove = append(change.FlagsRemove, flag)
+			}
+		}
+		for _, flag := range c.Flags {
+			if !current[flag] {
+				change.FlagsAdd = append(change.FlagsAdd, flag)
+			}
+		}
+
+		if len(change.Fields) > 0 || len(change.FlagsAdd) > 0 || len(change.FlagsRemove) > 0 {
+			change.Action = ActionUpdate
+		}
+		out = append(out, change)
+	}
+	return out, nil
+}
+
+func (c *Change) diff(field string, from string, to string) {
+	if from != to {
+		c.Fields = append(c.Fields, FieldChange{Field: field, From: from, To: to})
+	}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Apply removes flags first, so flag can be moved between challenges in one run.
+// Everything is applied in one transaction, failed import leaves tasks as they were.
+// Returns number of created or updated tasks, running servers reload flags when it is not zero.
+func Apply(ctx context.Context, dbSrv *db.DatabaseInternal, taskDB *models.TaskInternal, changes []*Change) (int, error) {
+	applied := 0
+	created := make(map[*Change]int)
+	err := dbSrv.Tx(ctx, func(ctx context.Context) error {
+		for _, change := range changes {
+			for _, flag := range change.FlagsRemove {
+				if err := taskDB.DeleteFlag(ctx, change.TaskID, flag.ID); err != nil {
+					return fmt.Errorf("%s: remove flag: %w", change.Challenge.Path, err)
+				}
+			}
+		}
+
+		for _, change := range changes {
+			if change.Action != ActionUnchanged {
+				applied++
+			}
+			c := change.Challenge
+			slug := c.Slug
+			task := models.TaskXXX{
+				ID:          change.TaskID,
+				Slug:        &slug,
+				Name:        c.Name,
+				Description: c.Description,
+				Category:    c.Category,
+				Difficult:   c.Difficulty,
+				StartedAt:   c.ReleaseAt,
+			}
+
+			taskID := change.TaskID
+			switch {
+			case change.Action == ActionCreate:
+				var err error
+				taskID, err = taskDB.AddTask(ctx, task)
+				if err != nil {
+					return fmt.Errorf("%s: add task: %w", c.Path, err)
+				}
+				created[change] = taskID
+			case len(change.Fields) > 0:
+				if err := taskDB.UpdateTask(ctx, task); err != nil {
+					return fmt.Errorf("%s: update task: %w", c.Path, err)
+				}
+			}
+
+			for _, flag := range change.FlagsAdd {
+				if _, err := taskDB.AddFlag(ctx, taskID, flag); err != nil {
+					return fmt.Errorf("%s: add flag: %w", c.Path, err)
+				}
+			}
+		}
+
+		if applied > 0 {
+			if err := taskDB.BumpFlagsVersion(ctx); err != nil {
+				return fmt.Errorf("bump flags version: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// ids of rolled back tasks would be wrong, so they are set only after commit
+	for change, taskID := range created {
+		change.TaskID = taskID
+	}
+	return applied, nil
+}
+
+// PrintPlan writes diff in "+ added", "- removed", "~ changed" format
+func PrintPlan(w io.Writer, changes []*Change) {
+	for _, change := range changes {
+		c := change.Challenge
+		switch change.Action {
+		case ActionCreate:
+			fmt.Fprintf(w, "+ task %s (%s)\n", c.Slug, c.Path)
+		case ActionUpdate:
+			fmt.Fprintf(w, "~ task %s #%d (%s)\n", c.Slug, change.TaskID, c.Path)
+		default:
+			fmt.Fprintf(w, "  task %s #%d unchanged\n", c.Slug, change.TaskID)
+			continue
+		}
+		for _, field := range change.Fields {
+			fmt.Fprintf(w, "    ~ %s: %q -> %q\n", field.Field, field.From, field.To)
+		}
+		for _, flag := range change.FlagsRemove {
+			fmt.Fprintf(w, "    - flag %s\n", flag.Flag)
+		}
+		for _, flag := range change.FlagsAdd {
+			fmt.Fprintf(w, "    + flag %s\n", flag)
+		}
+		if len(c.Files) > 0 {
+			fmt.Fprintf(w, "    ! %d files not imported, attachments are not supported\n", len(c.Files))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package challenge
+
+import (
+	"context"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"database/sql"
+	"testing"
+)
+
+func TestApplyBumpsFlagsVersion(t *testing.T) {
+	ctx := context.Background()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbSrv.Close(ctx)
+	if _, err := dbSrv.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	taskDB := models.NewTaskDB(dbSrv)
+
+	apply := func(challenges []*Challenge) (int, int64) {
+		t.Helper()
+		before, err := taskDB.GetFlagsVersion(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, err := Plan(ctx, taskDB, challenges)
+		if err != nil {
+			t.Fatal(err)
+		}
+		applied, err := Apply(ctx, dbSrv, taskDB, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := taskDB.GetFlagsVersion(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return applied, after - before
+	}
+
+	c := &Challenge{Path: "web/login", Slug: "login", Name: "Login", Category: "web", Difficulty: "easy", Flags: []string{"ctf{a}"}}
+	if applied, bumped := apply([]*Challenge{c}); applied != 1 || bumped != 1 {
+		t.Fatalf("create: applied %d, bumped %d", applied, bumped)
+	}
+	if applied, bumped := apply([]*Challenge{c}); applied != 0 || bumped != 0 {
+		t.Fatalf("unchanged: applied %d, bumped %d", applied, bumped)
+	}
+	c.Flags = []string{"ctf{b}"}
+	if applied, bumped := apply([]*Challenge{c}); applied != 1 || bumped != 1 {
+		t.Fatalf("flag changed: applied %d, bumped %d", applied, bumped)
+	}
+}
+
+// TestApplyRollback flag of other challenge fails the import after first task was created
+func TestApplyRollback(t *testing.T) {
+	ctx := context.Background()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbSrv.Close(ctx)
+	if _, err := dbSrv.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	taskDB := models.NewTaskDB(dbSrv)
+
+	challenges := []*Challenge{
+		{Path: "web/login", Slug: "login", Name: "Login", Category: "web", Difficulty: "easy", Flags: []string{"ctf{a}"}},
+		{Path: "web/admin", Slug: "admin", Name: "Admin", Category: "web", Difficulty: "easy", Flags: []string{"ctf{a}"}},
+	}
+	changes, err := Plan(ctx, taskDB, challenges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Apply(ctx, dbSrv, taskDB, changes); err == nil {
+		t.Fatal("import with duplicated flag succeeded")
+	}
+	if changes[0].TaskID != 0 {
+		t.Fatalf("task id %d set by rolled back import", changes[0].TaskID)
+	}
+
+	if _, err := taskDB.GetBySlug(ctx, "login"); err != sql.ErrNoRows {
+		t.Fatalf("task of failed import: %v", err)
+	}
+	version, err := taskDB.GetFlagsVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Fatalf("flags version %d after failed import", version)
+	}
+}
@@ -0,0 +1,585 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"ctfplatform/actions"
+	"ctfplatform/models"
+	"ctfplatform/storage"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	register("task list", "", "list all tasks", taskList)
+	register("task create", "-name -category -difficult [-slug -description -release <RFC3339>]", "create task", taskCreate)
+	register("task release", "<task id> [-at <RFC3339>]", "release task now or at given time", taskRelease)
+
+	register("flag list", "<task id>", "list task flags", flagList)
+	register("flag add", "<task id> <flag>", "add flag to task", flagAdd)
+	register("flag remove", "<task id> <flag id>", "remove flag from task", flagRemove)
+	register("flag reload", "", "make running servers reload flags cache", flagReload)
+
+	register("announcement list", "", "list announcements", announcementList)
+	register("announcement post", "<title> <description>", "post announcement", announcementPost)
+
+	register("team list", "", "list all teams", teamList)
+	register("team activate", "<team id>", "mark team email as verified", teamSetActive(true))
+	register("team deactivate", "<team id>", "mark team email as not verified", teamSetActive(false))
+	register("team ban", "<team id> <reason>", "ban team, it can not log in nor submit flags", teamSetState(models.TeamStateBanned))
+	register("team disqualify", "<team id> <reason>", "disqualify team, its solves are ignored", teamSetState(models.TeamStateDisqualified))
+	register("team hide", "<team id> [reason]", "hide team from scoreboard and teams list", teamSetState(models.TeamStateHidden))
+	register("team restore", "<team id> [reason]", "restore banned, disqualified or hidden team", teamSetState(models.TeamStateActive))
+	register("team moderation", "<team id>", "list team moderation log", teamModeration)
+	register("team reset-password", "<team id> [-password <password>]", "set new password (random when not given) and revoke sessions", teamResetPassword)
+
+	register("solve list", "[-team <id> -task <id> -from <RFC3339> -to <RFC3339> -freeze only|exclude -limit <n> -cursor <cursor>]", "list solves, newest first", solveList)
+
+	register("scoreboard", "", "print current scoreboard", scoreboard)
+
+	register("migrate up", "", "apply missing db migrations", migrateUp)
+	register("migrate status", "", "list db migrations", migrateStatus)
+
+	register("avatar migrate", "<from> <to>", "copy team avatars between storages (db, fs, s3), use larger -timeout for many teams", avatarMigrate)
+	register("avatar list", "[pending|approved|rejected]", "list avatars waiting for review (AVATAR_MODERATION)", avatarList)
+	register("avatar approve", "<moderation id>", "make pending avatar public", avatarApprove)
+	register("avatar reject", "<moderation id> <reason>", "reject pending avatar, reason is shown to team", avatarReject)
+}
+
+/// tasks
+
+func printTasks(a *app, tasks []actions.AdminTask) error {
+	rows := make([][]string, len(tasks))
+	for i, task := range tasks {
+		slug := "-"
+		if task.Slug != nil {
+			slug = *task.Slug
+		}
+		rows[i] = []string{strconv.Itoa(task.ID), slug, task.Name, task.Category, task.Difficult, formatTime(task.StartedAt)}
+	}
+	return a.print([]string{"ID", "SLUG", "NAME", "CATEGORY", "DIFFICULT", "RELEASED"}, rows, tasks)
+}
+
+func taskList(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	tasks, err := a.adminSrv.GetTasks(ctx)
+	if err != nil {
+		return err
+	}
+	return printTasks(a, tasks)
+}
+
+func taskCreate(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("task create", flag.ContinueOnError)
+	slug := fs.String("slug", "", "")
+	name := fs.String("name", "", "")
+	category := fs.String("category", "", "")
+	difficult := fs.String("difficult", "", "")
+	description := fs.String("description", "", "")
+	release := fs.String("release", "", "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+	if len(*name) == 0 || len(*category) == 0 || len(*difficult) == 0 {
+		return errUsage
+	}
+
+	task := actions.AdminTask{
+		Name:        *name,
+		Description: *description,
+		Category:    *category,
+		Difficult:   *difficult,
+	}
+	if len(*slug) > 0 {
+		task.Slug = slug
+	}
+	if len(*release) > 0 {
+		releaseAt, err := time.Parse(time.RFC3339, *release)
+		if err != nil {
+			return fmt.Errorf("invalid release time: %w", err)
+		}
+		task.StartedAt = &releaseAt
+	}
+
+	created, err := a.adminSrv.CreateTask(ctx, task)
+	if err != nil {
+		return err
+	}
+	return printTasks(a, []actions.AdminTask{*created})
+}
+
+func taskRelease(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	taskID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("task release", flag.ContinueOnError)
+	at := fs.String("at", "", "")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	releaseAt := time.Now()
+	if len(*at) > 0 {
+		releaseAt, err = time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("invalid release time: %w", err)
+		}
+	}
+
+	task, err := a.adminSrv.GetTask(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	task.StartedAt = &releaseAt
+	task, err = a.adminSrv.UpdateTask(ctx, *task)
+	if err != nil {
+		return err
+	}
+	return printTasks(a, []actions.AdminTask{*task})
+}
+
+/// flags
+
+func printFlags(a *app, flags []actions.AdminFlag) error {
+	rows := make([][]string, len(flags))
+	for i, taskFlag := range flags {
+		rows[i] = []string{strconv.Itoa(taskFlag.ID), strconv.Itoa(taskFlag.TaskID), taskFlag.Flag}
+	}
+	return a.print([]string{"ID", "TASK ID", "FLAG"}, rows, flags)
+}
+
+func flagList(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	taskID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	flags, err := a.adminSrv.GetFlags(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	return printFlags(a, flags)
+}
+
+func flagAdd(ctx context.Context, a *app, args []string) error {
+	if len(args) != 2 || len(args[1]) == 0 {
+		return errUsage
+	}
+	taskID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	taskFlag, err := a.adminSrv.AddFlag(ctx, taskID, args[1])
+	if err != nil {
+		return err
+	}
+	return printFlags(a, []actions.AdminFlag{*taskFlag})
+}
+
+func flagRemove(ctx context.Context, a *app, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	taskID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	flagID, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+	if err := a.adminSrv.DeleteFlag(ctx, taskID, flagID); err != nil {
+		return err
+	}
+	return a.done(fmt.Sprintf("flag #%d removed", flagID), map[string]int{"id": flagID})
+}
+
+func flagReload(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	state, err := a.adminSrv.ReloadFlagCache(ctx)
+	if err != nil {
+		return err
+	}
+	return a.done(fmt.Sprintf("flags version bumped to %d", state.Version), state)
+}
+
+/// announcements
+
+func printAnnouncements(a *app, announcements []actions.Announcement) error {
+	rows := make([][]string, len(announcements))
+	for i, announcement := range announcements {
+		rows[i] = []string{strconv.Itoa(announcement.ID), formatTime(&announcement.CreatedAt), announcement.Title}
+	}
+	return a.print([]string{"ID", "CREATED", "TITLE"}, rows, announcements)
+}
+
+func announcementList(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	announcements, err := a.adminSrv.GetAnnouncements(ctx)
+	if err != nil {
+		return err
+	}
+	return printAnnouncements(a, announcements)
+}
+
+func announcementPost(ctx context.Context, a *app, args []string) error {
+	if len(args) != 2 || len(args[0]) == 0 {
+		return errUsage
+	}
+	announcement, err := a.adminSrv.CreateAnnouncement(ctx, actions.Announcement{
+		Title:       args[0],
+		Description: args[1],
+	})
+	if err != nil {
+		return err
+	}
+	return printAnnouncements(a, []actions.Announcement{*announcement})
+}
+
+/// teams
+
+func teamList(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	teams, err := a.adminSrv.GetTeams(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(teams))
+	for i, team := range teams {
+		rows[i] = []string{strconv.Itoa(team.ID), team.Name, team.Email, strconv.FormatBool(team.Active), team.State, team.Country}
+	}
+	return a.print([]string{"ID", "NAME", "EMAIL", "ACTIVE", "STATE", "COUNTRY"}, rows, teams)
+}
+
+func teamSetActive(active bool) func(ctx context.Context, a *app, args []string) error {
+	return func(ctx context.Context, a *app, args []string) error {
+		if len(args) != 1 {
+			return errUsage
+		}
+		teamID, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+		if err := a.adminSrv.SetTeamActive(ctx, teamID, active); err != nil {
+			return err
+		}
+		return a.done(fmt.Sprintf("team #%d active: %t", teamID, active), map[string]interface{}{"id": teamID, "active": active})
+	}
+}
+
+// teamSetState reason is all remaining args, so it does not have to be quoted
+func teamSetState(state string) func(ctx context.Context, a *app, args []string) error {
+	return func(ctx context.Context, a *app, args []string) error {
+		if len(args) == 0 {
+			return errUsage
+		}
+		teamID, err := parseID(args[0])
+		if err != nil {
+			return err
+		}
+		if len(a.admin) == 0 {
+			return errors.New("admin name is required, use -admin")
+		}
+		team, err := a.adminSrv.SetTeamState(ctx, teamID, a.admin, state, strings.Join(args[1:], " "))
+		if err == actions.InvalidReason {
+			return errUsage
+		} else if err != nil {
+			return err
+		}
+		return a.done(fmt.Sprintf("team #%d state: %s", teamID, team.State), team)
+	}
+}
+
+func teamModeration(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	teamID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	moderations, err := a.adminSrv.GetTeamModerations(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(moderations))
+	for i, moderation := range moderations {
+		rows[i] = []string{formatTime(&moderation.CreatedAt), moderation.Admin, moderation.State, moderation.Reason}
+	}
+	return a.print([]string{"AT", "ADMIN", "STATE", "REASON"}, rows, moderations)
+}
+
+func teamResetPassword(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	teamID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("team reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	if len(*password) == 0 {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		*password = base64.RawURLEncoding.EncodeToString(b)
+	} else if len(*password) < 8 {
+		return fmt.Errorf("password should have at least 8 characters")
+	}
+
+	if err := a.adminSrv.ResetTeamPassword(ctx, teamID, *password); err != nil {
+		return err
+	}
+	return a.done(fmt.Sprintf("team #%d password: %s", teamID, *password), map[string]interface{}{"id": teamID, "password": *password})
+}
+
+/// solves
+
+func solveList(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("solve list", flag.ContinueOnError)
+	teamID := fs.Int("team", 0, "")
+	taskID := fs.Int("task", 0, "")
+	from := fs.String("from", "", "")
+	to := fs.String("to", "", "")
+	freeze := fs.String("freeze", "", "")
+	limit := fs.Int("limit", 0, "")
+	cursor := fs.String("cursor", "", "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	filter := actions.AdminSolveFilter{
+		TeamID: *teamID,
+		TaskID: *taskID,
+		Cursor: *cursor,
+		Limit:  *limit,
+	}
+	if len(*from) > 0 {
+		fromAt, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			return fmt.Errorf("invalid from time: %w", err)
+		}
+		filter.From = fromAt
+	}
+	if len(*to) > 0 {
+		toAt, err := time.Parse(time.RFC3339, *to)
+		if err != nil {
+			return fmt.Errorf("invalid to time: %w", err)
+		}
+		filter.To = toAt
+	}
+	switch *freeze {
+	case "":
+	case "only", "exclude":
+		duringFreeze := *freeze == "only"
+		filter.Freeze = &duringFreeze
+	default:
+		return errUsage
+	}
+
+	page, err := a.adminSrv.GetSolves(ctx, filter)
+	if err == actions.InvalidFilter {
+		return errUsage
+	} else if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(page.Solves))
+	for i, solve := range page.Solves {
+		rows[i] = []string{strconv.Itoa(solve.ID), formatTime(&solve.CreatedAt), strconv.Itoa(solve.TeamID), solve.TeamName, strconv.Itoa(solve.TaskID), solve.TaskName, strconv.FormatBool(solve.Freeze)}
+	}
+	if err := a.print([]string{"ID", "AT", "TEAM ID", "TEAM", "TASK ID", "TASK", "FREEZE"}, rows, page); err != nil {
+		return err
+	}
+	if len(page.NextCursor) > 0 && !a.json {
+		fmt.Fprintf(a.out, "next page: -cursor %s\n", page.NextCursor)
+	}
+	return nil
+}
+
+/// scoreboard
+
+func scoreboard(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	rows, err := a.mainSrv.GetScoreboard(ctx)
+	if err != nil {
+		return err
+	}
+
+	table := make([][]string, len(rows))
+	for i, row := range rows {
+		table[i] = []string{strconv.Itoa(i + 1), strconv.Itoa(row.Team.ID), row.Team.Name, row.Team.Country, strconv.Itoa(row.Points)}
+	}
+	return a.print([]string{"#", "TEAM ID", "NAME", "COUNTRY", "POINTS"}, table, rows)
+}
+
+/// migrations
+
+func migrateUp(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	applied, err := a.dbSrv.Migrate(ctx)
+	for _, m := range applied {
+		if !a.json {
+			fmt.Fprintf(a.out, "applied %d %s\n", m.Version, m.Name)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return a.done(fmt.Sprintf("schema version %d", a.dbSrv.LatestVersion()), map[string]int{"applied": len(applied), "version": a.dbSrv.LatestVersion()})
+}
+
+func migrateStatus(ctx context.Context, a *app, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	migrations, err := a.dbSrv.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(migrations))
+	for i, m := range migrations {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = formatTime(m.AppliedAt)
+		}
+		rows[i] = []string{strconv.Itoa(m.Version), m.Name, applied}
+	}
+	return a.print([]string{"VERSION", "NAME", "APPLIED"}, rows, migrations)
+}
+
+/// avatars
+
+func avatarMigrate(ctx context.Context, a *app, args []string) error {
+	if len(args) != 2 || args[0] == args[1] {
+		return errUsage
+	}
+	from, err := storage.NewStorageFromConfig(args[0], a.dbSrv)
+	if err != nil {
+		return err
+	}
+	to, err := storage.NewStorageFromConfig(args[1], a.dbSrv)
+	if err != nil {
+		return err
+	}
+
+	teams, err := a.adminSrv.GetTeams(ctx)
+	if err != nil {
+		return err
+	}
+
+	copied, missing := 0, 0
+	for _, team := range teams {
+		if len(team.Avatar) == 0 {
+			continue
+		}
+		payload, err := from.Get(ctx, team.Avatar)
+		if errors.Is(err, storage.ErrNotFound) {
+			if !a.json {
+				fmt.Fprintf(a.out, "team #%d avatar %s not found in %s\n", team.ID, team.Avatar, args[0])
+			}
+			missing++
+			continue
+		} else if err != nil {
+			return fmt.Errorf("get team #%d avatar: %w", team.ID, err)
+		}
+		if err := to.Put(ctx, team.ID, team.Avatar, payload); err != nil {
+			return fmt.Errorf("put team #%d avatar: %w", team.ID, err)
+		}
+		copied++
+	}
+	return a.done(fmt.Sprintf("copied %d avatars, %d missing", copied, missing), map[string]int{"copied": copied, "missing": missing})
+}
+
+func avatarList(ctx context.Context, a *app, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	state := ""
+	if len(args) == 1 {
+		state = args[0]
+	}
+	moderations, err := a.adminSrv.GetAvatarModerations(ctx, state)
+	if err == actions.InvalidAvatarState {
+		return errUsage
+	} else if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(moderations))
+	for i, moderation := range moderations {
+		rows[i] = []string{strconv.Itoa(moderation.ID), strconv.Itoa(moderation.TeamID), moderation.TeamName, formatTime(&moderation.CreatedAt), moderation.State, moderation.Admin, moderation.Reason}
+	}
+	return a.print([]string{"ID", "TEAM ID", "TEAM", "AT", "STATE", "ADMIN", "REASON"}, rows, moderations)
+}
+
+func avatarApprove(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	moderationID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	if len(a.admin) == 0 {
+		return errors.New("admin name is required, use -admin")
+	}
+	moderation, err := a.adminSrv.ApproveAvatar(ctx, moderationID, a.admin)
+	if err != nil {
+		return err
+	}
+	return a.done(fmt.Sprintf("team #%d avatar approved", moderation.TeamID), moderation)
+}
+
+// avatarReject reason is all remaining args, so it does not have to be quoted
+func avatarReject(ctx context.Context, a *app, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+	moderationID, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	if len(a.admin) == 0 {
+		return errors.New("admin name is required, use -admin")
+	}
+	moderation, err := a.adminSrv.RejectAvatar(ctx, moderationID, a.admin, strings.Join(args[1:], " "))
+	if err == actions.InvalidReason {
+		return errUsage
+	} else if err != nil {
+		return err
+	}
+	return a.done(fmt.Sprintf("team #%d avatar rejected", moderation.TeamID), moderation)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"ctfplatform/actions"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"ctfplatform/storage"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var errUsage = errors.New("invalid usage")
+
+type app struct {
+	dbSrv    *db.DatabaseInternal
+	mainSrv  *actions.MainInternal
+	adminSrv *actions.AdminInternal
+
+	json  bool
+	admin string
+	out   io.Writer
+}
+
+// print writes rows as table or v as json
+func (a *app) print(header []string, rows [][]string, v interface{}) error {
+	if a.json {
+		enc := json.NewEncoder(a.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// done prints result of command without output
+func (a *app) done(msg string, v interface{}) error {
+	if a.json {
+		return a.print(nil, nil, v)
+	}
+	fmt.Fprintln(a.out, msg)
+	return nil
+}
+
+// run checks schema first, only migrate commands work on outdated one
+func (a *app) run(ctx context.Context, name string, args []string) error {
+	if !strings.HasPrefix(name, "migrate ") {
+		if err := a.dbSrv.CheckSchema(ctx); err != nil {
+			return fmt.Errorf("%w, run migrate up first", err)
+		}
+	}
+	return commands[name].run(ctx, a, args)
+}
+
+type command struct {
+	args string
+	help string
+	run  func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = map[string]command{}
+
+func register(name string, args string, help string, run func(ctx context.Context, a *app, args []string) error) {
+	commands[name] = command{args: args, help: help, run: run}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "usage: %s [-json] [-admin <name>] <command> [args]\n\n", os.Args[0])
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s %s\t%s\n", name, commands[name].args, commands[name].help)
+	}
+	w.Flush()
+}
+
+func parseID(value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", value)
+	}
+	return id, nil
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func run() error {
+	jsonOutput := flag.Bool("json", false, "print json instead of table")
+	timeout := flag.Duration("timeout", 30*time.Second, "command timeout")
+	admin := flag.String("admin", os.Getenv("USER"), "admin name logged with moderation actions")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		return errUsage
+	}
+	name := args[0]
+	args = args[1:]
+	if _, exists := commands[name]; !exists && len(args) > 0 {
+		name = name + " " + args[0]
+		args = args[1:]
+	}
+	cmd, exists := commands[name]
+	if !exists {
+		usage()
+		return errUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	dbSrv, err := db.NewDB(config.Config.MysqlDsn)
+	if err != nil {
+		return err
+	}
+	defer dbSrv.Close(ctx)
+
+	// configured storage is needed to delete avatars of removed teams
+	avatarStorage, err := storage.NewStorageFromConfig(config.Config.AvatarStorage, dbSrv)
+	if err != nil {
+		return err
+	}
+
+	mainSrv := actions.NewRanking(models.NewTaskDB(dbSrv), models.NewTeamDB(dbSrv), models.NewAuditDB(dbSrv), models.NewAnnouncementDB(dbSrv), nil, avatarStorage, dbSrv)
+	a := &app{
+		dbSrv:    dbSrv,
+		mainSrv:  mainSrv,
+		adminSrv: actions.NewAdmin(mainSrv),
+		json:     *jsonOutput,
+		admin:    *admin,
+		out:      os.Stdout,
+	}
+
+	if err := a.run(ctx, name, args); err == errUsage {
+		fmt.Fprintf(os.Stderr, "usage: %s %s %s\n", os.Args[0], name, cmd.args)
+		return err
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func main() {
+	if err := run(); err == errUsage {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"ctfplatform/actions"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"ctfplatform/storage"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// newTestApp returns app with json output on in-memory sqlite database, migrated when migrate is true
+func newTestApp(t *testing.T, migrate bool) *app {
+	t.Helper()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbSrv.Close(context.Background()) })
+	if migrate {
+		if _, err := dbSrv.Migrate(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mainSrv := actions.NewRanking(models.NewTaskDB(dbSrv), models.NewTeamDB(dbSrv), models.NewAuditDB(dbSrv), models.NewAnnouncementDB(dbSrv), nil, storage.NewDBStorage(dbSrv), dbSrv)
+	return &app{
+		dbSrv:    dbSrv,
+		mainSrv:  mainSrv,
+		adminSrv: actions.NewAdmin(mainSrv),
+		json:     true,
+		admin:    "root",
+		out:      &bytes.Buffer{},
+	}
+}
+
+// runCommand decodes json output of command into out
+func runCommand(t *testing.T, a *app, out interface{}, name string, args ...string) {
+	t.Helper()
+	buf := a.out.(*bytes.Buffer)
+	buf.Reset()
+	if err := a.run(context.Background(), name, args); err != nil {
+		t.Fatalf("%s %v: %v", name, args, err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), out); err != nil {
+		t.Fatalf("%s %v output %q: %v", name, args, buf.String(), err)
+	}
+}
+
+func TestSchemaCheck(t *testing.T) {
+	a := newTestApp(t, false)
+	ctx := context.Background()
+
+	if err := a.run(ctx, "task list", nil); !errors.Is(err, db.ErrSchemaOutdated) {
+		t.Fatalf("task list on empty db: %v", err)
+	}
+
+	var migrations []db.MigrationStatus
+	runCommand(t, a, &migrations, "migrate status")
+	if len(migrations) == 0 || migrations[0].AppliedAt != nil {
+		t.Fatalf("status before migrate %+v", migrations)
+	}
+	var result map[string]int
+	runCommand(t, a, &result, "migrate up")
+	if result["applied"] != len(migrations) || result["version"] != a.dbSrv.LatestVersion() {
+		t.Fatalf("migrate up %v", result)
+	}
+
+	var tasks []actions.AdminTask
+	runCommand(t, a, &tasks, "task list")
+	if len(tasks) != 0 {
+		t.Fatalf("got %d tasks", len(tasks))
+	}
+}
+
+func TestTaskCommands(t *testing.T) {
+	a := newTestApp(t, true)
+	ctx := context.Background()
+
+	if err := a.run(ctx, "task create", []string{"-name", "Login"}); err != errUsage {
+		t.Fatalf("task create without category: %v", err)
+	}
+	var tasks []actions.AdminTask
+	runCommand(t, a, &tasks, "task create", "-name", "Login", "-category", "web", "-difficult", "easy", "-slug", "login")
+	if len(tasks) != 1 || tasks[0].Slug == nil || *tasks[0].Slug != "login" || tasks[0].StartedAt != nil {
+		t.Fatalf("created %+v", tasks)
+	}
+	taskID := strconv.Itoa(tasks[0].ID)
+
+	runCommand(t, a, &tasks, "task release", taskID, "-at", "2030-01-02T03:04:05Z")
+	if tasks[0].StartedAt == nil || tasks[0].StartedAt.UTC().Format("2006-01-02T15:04:05Z") != "2030-01-02T03:04:05Z" {
+		t.Fatalf("released %+v", tasks[0].StartedAt)
+	}
+
+	var flags []actions.AdminFlag
+	runCommand(t, a, &flags, "flag add", taskID, "ctf{a}")
+	flagID := strconv.Itoa(flags[0].ID)
+	runCommand(t, a, &flags, "flag list", taskID)
+	if len(flags) != 1 || flags[0].Flag != "ctf{a}" {
+		t.Fatalf("flags %+v", flags)
+	}
+	var removed map[string]int
+	runCommand(t, a, &removed, "flag remove", taskID, flagID)
+	runCommand(t, a, &flags, "flag list", taskID)
+	if len(flags) != 0 {
+		t.Fatalf("flags after remove %+v", flags)
+	}
+	if err := a.run(ctx, "flag list", []string{"x"}); err == nil {
+		t.Fatal("flag list with invalid id succeeded")
+	}
+}
+
+func TestTeamCommands(t *testing.T) {
+	a := newTestApp(t, true)
+	ctx := context.Background()
+
+	team := models.TeamXXX{Name: "team", Email: "team@example.com", Country: "PL"}
+	if err := team.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.mainSrv.AddTeam(ctx, team); err != nil {
+		t.Fatal(err)
+	}
+	var teams []actions.AdminTeam
+	runCommand(t, a, &teams, "team list")
+	if len(teams) != 1 || teams[0].Name != team.Name {
+		t.Fatalf("teams %+v", teams)
+	}
+	teamID := strconv.Itoa(teams[0].ID)
+
+	var result map[string]interface{}
+	runCommand(t, a, &result, "team deactivate", teamID)
+	runCommand(t, a, &teams, "team list")
+	if teams[0].Active {
+		t.Fatal("team active after deactivate")
+	}
+	runCommand(t, a, &result, "team activate", teamID)
+	runCommand(t, a, &teams, "team list")
+	if !teams[0].Active {
+		t.Fatal("team not active after activate")
+	}
+
+	if err := a.run(ctx, "team ban", []string{teamID}); err != errUsage {
+		t.Fatalf("ban without reason: %v", err)
+	}
+	var banned actions.AdminTeam
+	runCommand(t, a, &banned, "team ban", teamID, "flag", "sharing")
+	if banned.State != models.TeamStateBanned {
+		t.Fatalf("state %s after ban", banned.State)
+	}
+	var moderations []actions.TeamModeration
+	runCommand(t, a, &moderations, "team moderation", teamID)
+	if len(moderations) != 1 || moderations[0].Reason != "flag sharing" || moderations[0].Admin != "root" {
+		t.Fatalf("moderations %+v", moderations)
+	}
+
+	if err := a.run(ctx, "team reset-password", []string{teamID, "-password", "short"}); err == nil {
+		t.Fatal("short password accepted")
+	}
+	runCommand(t, a, &result, "team reset-password", teamID, "-password", "newpassword")
+	teamData, err := a.mainSrv.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !teamData.EqualPassword("newpassword") {
+		t.Fatal("password not changed")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"ctfplatform/challenge"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func run() error {
+	dryRun := flag.Bool("dry-run", false, "only print diff against db")
+	timeout := flag.Duration("timeout", time.Minute, "import timeout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-dry-run] <challenges dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return errors.New("challenges dir is required")
+	}
+
+	challenges, err := challenge.Load(flag.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	dbSrv, err := db.NewDB(config.Config.MysqlDsn)
+	if err != nil {
+		return err
+	}
+	defer dbSrv.Close(ctx)
+	if err := dbSrv.CheckSchema(ctx); err != nil {
+		return err
+	}
+	taskSrv := models.NewTaskDB(dbSrv)
+
+	changes, err := challenge.Plan(ctx, taskSrv, challenges)
+	if err != nil {
+		return err
+	}
+	challenge.PrintPlan(os.Stdout, changes)
+	if *dryRun {
+		return nil
+	}
+
+	applied, err := challenge.Apply(ctx, dbSrv, taskSrv, changes)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d challenges, %d changed\n", len(changes), applied)
+	return nil
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
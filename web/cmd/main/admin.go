@@ -0,0 +1,827 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"ctfplatform/actions"
+	"ctfplatform/config"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HttpErrAlreadyExists = "already_exists"
+	HttpErrHasSolves     = "has_solves"
+	HttpErrHasModeration = "has_moderation"
+	HttpErrRequiredField = "required_field"
+	HttpErrInvalidState  = "invalid_state"
+	HttpErrInvalidReason = "invalid_reason"
+	HttpErrNotPending    = "not_pending"
+	HttpErrInvalidFilter = "invalid_filter"
+	HttpErrReservedName  = "reserved_name"
+)
+
+// checkAdminToken compares with every configured token, so timing does not tell which one matched
+func checkAdminToken(token []byte) (string, bool) {
+	found := ""
+	for name, adminToken := range config.Config.AdminTokens {
+		if len(adminToken) > 0 && subtle.ConstantTimeCompare(token, []byte(adminToken)) == 1 {
+			found = name
+		}
+	}
+	return found, len(found) > 0
+}
+
+// AdminMiddleware should be wrapped with TimeoutMiddleware, admin api is disabled when no tokens are configured
+func AdminMiddleware(h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		if len(config.Config.AdminTokens) == 0 {
+			ctx.Error(HttpErrNotFound, http.StatusNotFound)
+			return
+		}
+
+		authHeader := ctx.Request.Header.Peek("Authorization")
+		if !bytes.HasPrefix(authHeader, []byte("Bearer ")) {
+			logger.Warning("admin authorization header missing")
+			ctx.Error(HttpErrNotAuthorize, http.StatusUnauthorized)
+			return
+		}
+		adminName, ok := checkAdminToken(authHeader[len("Bearer "):])
+		if !ok {
+			logger.Warning("invalid admin token")
+			ctx.Error(HttpErrNotAuthorize, http.StatusUnauthorized)
+			return
+		}
+
+		ctx.SetUserValue("_admin", adminName)
+		ctx.SetUserValue("_logger", logger.WithField("admin", adminName))
+		h(ctx)
+	}
+}
+
+func getIDParam(ctx *fasthttp.RequestCtx, name string) (int, bool) {
+	id, err := strconv.Atoi(ctx.UserValue(name).(string))
+	if err != nil {
+		GetLogger(ctx).WithError(err).Warning("invalid " + name)
+		ctx.Error(HttpErrNotFound, http.StatusNotFound)
+		return 0, false
+	}
+	return id, true
+}
+
+func adminError(ctx *fasthttp.RequestCtx, logger *logrus.Entry, err error, msg string) {
+	if err == actions.NotFound {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrNotFound, http.StatusNotFound)
+	} else if err == actions.AlreadyExists {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrAlreadyExists, http.StatusConflict)
+	} else if err == actions.HasSolves {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrHasSolves, http.StatusConflict)
+	} else if err == actions.HasModeration {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrHasModeration, http.StatusConflict)
+	} else if err == actions.InvalidTeamState {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrInvalidState, http.StatusBadRequest)
+	} else if err == actions.InvalidReason {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrInvalidReason, http.StatusBadRequest)
+	} else if err == actions.InvalidAvatarState {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrInvalidState, http.StatusBadRequest)
+	} else if err == actions.AvatarNotPending {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrNotPending, http.StatusConflict)
+	} else if err == actions.InvalidFilter {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrInvalidFilter, http.StatusBadRequest)
+	} else if err == actions.ReservedTeamName {
+		logger.WithError(err).Warning(msg)
+		ctx.Error(HttpErrReservedName, http.StatusBadRequest)
+	} else {
+		logger.WithError(err).Error(msg)
+		ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+	}
+}
+
+func adminWrite(ctx *fasthttp.RequestCtx, status int, out interface{}) {
+	ctx.SetStatusCode(status)
+	ctx.SetContentType("application/json")
+	json.NewEncoder(ctx.Response.BodyWriter()).Encode(out)
+}
+
+/// tasks
+
+type adminTaskRequest struct {
+	Slug        *string    `json:"slug"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Category    string     `json:"category"`
+	Difficult   string     `json:"difficult"`
+	StartedAt   *time.Time `json:"started_at"`
+}
+
+func (r *adminTaskRequest) parse(ctx *fasthttp.RequestCtx) bool {
+	logger := GetLogger(ctx)
+	if err := json.Unmarshal(ctx.PostBody(), r); err != nil {
+		logger.WithError(err).Warning("invalid json")
+		ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+		return false
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	if len(r.Name) == 0 || len(r.Category) == 0 || len(r.Difficult) == 0 {
+		logger.Warning("task name, category or difficult missing")
+		ctx.Error(HttpErrRequiredField, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (r *adminTaskRequest) task(taskID int) actions.AdminTask {
+	return actions.AdminTask{
+		ID:          taskID,
+		Slug:        r.Slug,
+		Name:        r.Name,
+		Description: r.Description,
+		Category:    r.Category,
+		Difficult:   r.Difficult,
+		StartedAt:   r.StartedAt,
+	}
+}
+
+func handleAdminTasks(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		tasks, err := adminSrv.GetTasks(GetCtx(ctx))
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get tasks err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, tasks)
+	}
+}
+
+func handleAdminTask(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		taskID, ok := getIDParam(ctx, "task_id")
+		if !ok {
+			return
+		}
+		task, err := adminSrv.GetTask(GetCtx(ctx), taskID)
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get task err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, task)
+	}
+}
+
+func handleAdminTaskCreate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		input := adminTaskRequest{}
+		if !input.parse(ctx) {
+			return
+		}
+		task, err := adminSrv.CreateTask(GetCtx(ctx), input.task(0))
+		if err != nil {
+			adminError(ctx, logger, err, "create task err")
+			return
+		}
+		logger.WithField("task_id", task.ID).Info("task created")
+		adminWrite(ctx, http.StatusCreated, task)
+	}
+}
+
+func handleAdminTaskUpdate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		taskID, ok := getIDParam(ctx, "task_id")
+		if !ok {
+			return
+		}
+		input := adminTaskRequest{}
+		if !input.parse(ctx) {
+			return
+		}
+		task, err := adminSrv.UpdateTask(GetCtx(ctx), input.task(taskID))
+		if err != nil {
+			adminError(ctx, logger, err, "update task err")
+			return
+		}
+		logger.WithField("task_id", task.ID).Info("task updated")
+		adminWrite(ctx, http.StatusOK, task)
+	}
+}
+
+func handleAdminTaskDelete(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		taskID, ok := getIDParam(ctx, "task_id")
+		if !ok {
+			return
+		}
+		if err := adminSrv.DeleteTask(GetCtx(ctx), taskID); err != nil {
+			adminError(ctx, logger, err, "delete task err")
+			return
+		}
+		logger.WithField("task_id", taskID).Info("task deleted")
+		ctx.SetStatusCode(http.StatusNoContent)
+	}
+}
+
+/// task flags
+
+func handleAdminFlags(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		taskID, ok := getIDParam(ctx, "task_id")
+		if !ok {
+			return
+		}
+		flags, err := adminSrv.GetFlags(GetCtx(ctx), taskID)
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get flags err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, flags)
+	}
+}
+
+func handleAdminFlagCreate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	type request struct {
+		Flag string `json:"flag"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		taskID, ok := getIDParam(ctx, "task_id")
+		if !ok {
+			return
+		}
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+		if len(input.Flag) == 0 {
+			logger.Warning("flag missing")
+			ctx.Error(HttpErrRequiredField, http.StatusBadRequest)
+			return
+		}
+
+		flag, err := adminSrv.AddFlag(GetCtx(ctx), taskID, input.Flag)
+		if err != nil {
+			adminError(ctx, logger, err, "add flag err")
+			return
+		}
+		logger.WithFields(logrus.Fields{
+			"task_id": taskID,
+			"flag_id": flag.ID,
+		}).Info("flag added")
+		adminWrite(ctx, http.StatusCreated, flag)
+	}
+}
+
+func handleAdminFlagDelete(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		taskID, ok := getIDParam(ctx, "task_id")
+		if !ok {
+			return
+		}
+		flagID, ok := getIDParam(ctx, "flag_id")
+		if !ok {
+			return
+		}
+		if err := adminSrv.DeleteFlag(GetCtx(ctx), taskID, flagID); err != nil {
+			adminError(ctx, logger, err, "delete flag err")
+			return
+		}
+		logger.WithFields(logrus.Fields{
+			"task_id": taskID,
+			"flag_id": flagID,
+		}).Info("flag deleted")
+		ctx.SetStatusCode(http.StatusNoContent)
+	}
+}
+
+func handleAdminFlagCache(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		adminWrite(ctx, http.StatusOK, adminSrv.GetFlagCache())
+	}
+}
+
+func handleAdminFlagCacheReload(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		state, err := adminSrv.ReloadFlagCache(GetCtx(ctx))
+		if err != nil {
+			adminError(ctx, logger, err, "reload flag cache err")
+			return
+		}
+		logger.WithField("version", state.Version).Info("flag cache reloaded")
+		adminWrite(ctx, http.StatusOK, state)
+	}
+}
+
+/// announcements
+
+type adminAnnouncementRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+func (r *adminAnnouncementRequest) parse(ctx *fasthttp.RequestCtx) bool {
+	logger := GetLogger(ctx)
+	if err := json.Unmarshal(ctx.PostBody(), r); err != nil {
+		logger.WithError(err).Warning("invalid json")
+		ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+		return false
+	}
+	r.Title = strings.TrimSpace(r.Title)
+	if len(r.Title) == 0 {
+		logger.Warning("announcement title missing")
+		ctx.Error(HttpErrRequiredField, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func handleAdminAnnouncements(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		announcements, err := adminSrv.GetAnnouncements(GetCtx(ctx))
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get announcements err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, announcements)
+	}
+}
+
+func handleAdminAnnouncementCreate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		input := adminAnnouncementRequest{}
+		if !input.parse(ctx) {
+			return
+		}
+		announcement, err := adminSrv.CreateAnnouncement(GetCtx(ctx), actions.Announcement{
+			Title:       input.Title,
+			Description: input.Description,
+		})
+		if err != nil {
+			adminError(ctx, logger, err, "create announcement err")
+			return
+		}
+		logger.WithField("announcement_id", announcement.ID).Info("announcement created")
+		adminWrite(ctx, http.StatusCreated, announcement)
+	}
+}
+
+func handleAdminAnnouncementUpdate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		announcementID, ok := getIDParam(ctx, "announcement_id")
+		if !ok {
+			return
+		}
+		input := adminAnnouncementRequest{}
+		if !input.parse(ctx) {
+			return
+		}
+		announcement, err := adminSrv.UpdateAnnouncement(GetCtx(ctx), actions.Announcement{
+			ID:          announcementID,
+			Title:       input.Title,
+			Description: input.Description,
+		})
+		if err != nil {
+			adminError(ctx, logger, err, "update announcement err")
+			return
+		}
+		logger.WithField("announcement_id", announcementID).Info("announcement updated")
+		adminWrite(ctx, http.StatusOK, announcement)
+	}
+}
+
+func handleAdminAnnouncementDelete(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		announcementID, ok := getIDParam(ctx, "announcement_id")
+		if !ok {
+			return
+		}
+		if err := adminSrv.DeleteAnnouncement(GetCtx(ctx), announcementID); err != nil {
+			adminError(ctx, logger, err, "delete announcement err")
+			return
+		}
+		logger.WithField("announcement_id", announcementID).Info("announcement deleted")
+		ctx.SetStatusCode(http.StatusNoContent)
+	}
+}
+
+/// teams
+
+// adminTeamRequest missing active keeps current value on update, new team is inactive then
+type adminTeamRequest struct {
+	Name     string      `json:"name"`
+	Email    EmailData   `json:"email"`
+	Password string      `json:"password"`
+	Active   *bool       `json:"active"`
+	Country  CountryData `json:"country"`
+
+	Affiliation string `json:"affiliation"`
+	Website     string `json:"website"`
+}
+
+// parse password is required only for new teams
+func (r *adminTeamRequest) parse(ctx *fasthttp.RequestCtx, passwordRequired bool) bool {
+	logger := GetLogger(ctx)
+
+	err := json.Unmarshal(ctx.PostBody(), r)
+	if errors.Is(err, ErrInvalidEmail) {
+		logger.WithError(err).Warning("invalid email")
+		ctx.Error(HttpErrInvalidEmail, http.StatusBadRequest)
+		return false
+	} else if errors.Is(err, ErrInvalidCountry) {
+		logger.WithError(err).Warning("invalid country")
+		ctx.Error(HttpErrInvalidCountry, http.StatusBadRequest)
+		return false
+	} else if err != nil {
+		logger.WithError(err).Warning("invalid json")
+		ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+		return false
+	}
+
+	r.Name = strings.TrimSpace(r.Name)
+	if !isASCII(r.Name) {
+		logger.WithField("team_name", r.Name).Warning("team name not ascii")
+		ctx.Error(HttpErrInvalidTeamNameAscii, http.StatusBadRequest)
+		return false
+	}
+	if len(r.Name) == 0 {
+		logger.Warning("team name to short")
+		ctx.Error(HttpErrInvalidTeamNameLength, http.StatusBadRequest)
+		return false
+	}
+	if (passwordRequired || len(r.Password) > 0) && len(r.Password) < 8 {
+		logger.Warning("password invalid length")
+		ctx.Error(HttpErrInvalidPasswordLength, http.StatusBadRequest)
+		return false
+	}
+	if len(r.Website) > 0 && !strings.HasPrefix(r.Website, "https://") {
+		logger.Warning("invalid website")
+		ctx.Error(HttpErrInvalidWebsite, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (r *adminTeamRequest) team(teamID int) actions.AdminTeam {
+	return actions.AdminTeam{
+		ID:          teamID,
+		Name:        r.Name,
+		Email:       strings.TrimSpace(string(r.Email)),
+		Active:      r.Active != nil && *r.Active,
+		Country:     string(r.Country),
+		Affiliation: r.Affiliation,
+		Website:     r.Website,
+	}
+}
+
+func handleAdminTeams(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		teams, err := adminSrv.GetTeams(GetCtx(ctx))
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get teams err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, teams)
+	}
+}
+
+func handleAdminTeam(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		teamID, ok := getIDParam(ctx, "team_id")
+		if !ok {
+			return
+		}
+		team, err := adminSrv.GetTeam(GetCtx(ctx), teamID)
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get team err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, team)
+	}
+}
+
+func handleAdminTeamCreate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		input := adminTeamRequest{}
+		if !input.parse(ctx, true) {
+			return
+		}
+		team, err := adminSrv.CreateTeam(GetCtx(ctx), input.team(0), input.Password)
+		if err != nil {
+			adminError(ctx, logger, err, "create team err")
+			return
+		}
+		logger.WithField("team_id", team.ID).Info("team created")
+		adminWrite(ctx, http.StatusCreated, team)
+	}
+}
+
+func handleAdminTeamUpdate(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		teamID, ok := getIDParam(ctx, "team_id")
+		if !ok {
+			return
+		}
+		input := adminTeamRequest{}
+		if !input.parse(ctx, false) {
+			return
+		}
+		team, err := adminSrv.UpdateTeam(GetCtx(ctx), input.team(teamID), input.Password, input.Active)
+		if err != nil {
+			adminError(ctx, logger, err, "update team err")
+			return
+		}
+		logger.WithField("team_id", teamID).Info("team updated")
+		adminWrite(ctx, http.StatusOK, team)
+	}
+}
+
+func handleAdminTeamDelete(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		teamID, ok := getIDParam(ctx, "team_id")
+		if !ok {
+			return
+		}
+		if err := adminSrv.DeleteTeam(GetCtx(ctx), teamID); err != nil {
+			adminError(ctx, logger, err, "delete team err")
+			return
+		}
+		logger.WithField("team_id", teamID).Info("team deleted")
+		ctx.SetStatusCode(http.StatusNoContent)
+	}
+}
+
+func handleAdminTeamRevokeSessions(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		teamID, ok := getIDParam(ctx, "team_id")
+		if !ok {
+			return
+		}
+		if err := adminSrv.RevokeTeamSessions(GetCtx(ctx), teamID); err != nil {
+			adminError(ctx, logger, err, "revoke team sessions err")
+			return
+		}
+		logger.WithField("team_id", teamID).Info("team sessions revoked")
+		ctx.SetStatusCode(http.StatusNoContent)
+	}
+}
+
+func handleAdminTeamState(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	type request struct {
+		State  string `json:"state"`
+		Reason string `json:"reason"`
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		teamID, ok := getIDParam(ctx, "team_id")
+		if !ok {
+			return
+		}
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+		team, err := adminSrv.SetTeamState(GetCtx(ctx), teamID, ctx.UserValue("_admin").(string), input.State, input.Reason)
+		if err != nil {
+			adminError(ctx, logger, err, "set team state err")
+			return
+		}
+		logger.WithFields(logrus.Fields{"team_id": teamID, "state": team.State, "reason": team.StateReason}).Info("team state changed")
+		adminWrite(ctx, http.StatusOK, team)
+	}
+}
+
+func handleAdminTeamModerations(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		teamID, ok := getIDParam(ctx, "team_id")
+		if !ok {
+			return
+		}
+		moderations, err := adminSrv.GetTeamModerations(GetCtx(ctx), teamID)
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get team moderations err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, moderations)
+	}
+}
+
+/// avatars
+
+func handleAdminAvatars(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		moderations, err := adminSrv.GetAvatarModerations(GetCtx(ctx), string(ctx.QueryArgs().Peek("state")))
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get avatar moderations err")
+			return
+		}
+		adminWrite(ctx, http.StatusOK, moderations)
+	}
+}
+
+func handleAdminAvatarImage(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		moderationID, ok := getIDParam(ctx, "moderation_id")
+		if !ok {
+			return
+		}
+		avatarPayload, err := adminSrv.GetPendingAvatarImage(GetCtx(ctx), moderationID)
+		if err != nil {
+			adminError(ctx, GetLogger(ctx), err, "get pending avatar err")
+			return
+		}
+		ctx.SetStatusCode(http.StatusOK)
+		ctx.Response.Header.Set("Cache-Control", "no-store")
+		ctx.Response.Header.Set("Content-Type", "image/png")
+		ctx.Response.SetBodyRaw(avatarPayload)
+	}
+}
+
+func handleAdminAvatarApprove(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		moderationID, ok := getIDParam(ctx, "moderation_id")
+		if !ok {
+			return
+		}
+		moderation, err := adminSrv.ApproveAvatar(GetCtx(ctx), moderationID, ctx.UserValue("_admin").(string))
+		if err != nil {
+			adminError(ctx, logger, err, "approve avatar err")
+			return
+		}
+		logger.WithFields(logrus.Fields{"moderation_id": moderationID, "team_id": moderation.TeamID}).Info("avatar approved")
+		adminWrite(ctx, http.StatusOK, moderation)
+	}
+}
+
+func handleAdminAvatarReject(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	type request struct {
+		Reason string `json:"reason"`
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		moderationID, ok := getIDParam(ctx, "moderation_id")
+		if !ok {
+			return
+		}
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+		moderation, err := adminSrv.RejectAvatar(GetCtx(ctx), moderationID, ctx.UserValue("_admin").(string), input.Reason)
+		if err != nil {
+			adminError(ctx, logger, err, "reject avatar err")
+			return
+		}
+		logger.WithFields(logrus.Fields{"moderation_id": moderationID, "team_id": moderation.TeamID, "reason": moderation.Reason}).Info("avatar rejected")
+		adminWrite(ctx, http.StatusOK, moderation)
+	}
+}
+
+/// solves
+
+// parseSolveFilter query: team_id, task_id, from, to (RFC3339), freeze (only or exclude), cursor, limit
+func parseSolveFilter(args *fasthttp.Args) (actions.AdminSolveFilter, error) {
+	filter := actions.AdminSolveFilter{
+		Cursor: string(args.Peek("cursor")),
+	}
+	for name, value := range map[string]*int{"team_id": &filter.TeamID, "task_id": &filter.TaskID, "limit": &filter.Limit} {
+		if raw := args.Peek(name); len(raw) > 0 {
+			v, err := strconv.Atoi(string(raw))
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+			*value = v
+		}
+	}
+	for name, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := args.Peek(name); len(raw) > 0 {
+			v, err := time.Parse(time.RFC3339, string(raw))
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+			*value = v
+		}
+	}
+	duringFreeze := true
+	outsideFreeze := false
+	switch freeze := string(args.Peek("freeze")); freeze {
+	case "":
+	case "only":
+		filter.Freeze = &duringFreeze
+	case "exclude":
+		filter.Freeze = &outsideFreeze
+	default:
+		return filter, fmt.Errorf("freeze: unknown value %q", freeze)
+	}
+	return filter, nil
+}
+
+// csvCell team names are chosen by players, spreadsheet must not run them as formula
+func csvCell(value string) string {
+	if len(value) > 0 && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// handleAdminSolves next page cursor is also in X-Next-Cursor header, so csv pages can be fetched in a loop
+func handleAdminSolves(adminSrv *actions.AdminInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		logger := GetLogger(ctx)
+
+		filter, err := parseSolveFilter(ctx.QueryArgs())
+		if err != nil {
+			logger.WithError(err).Warning("invalid solves filter")
+			ctx.Error(HttpErrInvalidFilter, http.StatusBadRequest)
+			return
+		}
+		format := string(ctx.QueryArgs().Peek("format"))
+		if format != "" && format != "json" && format != "csv" {
+			logger.WithField("format", format).Warning("invalid solves format")
+			ctx.Error(HttpErrInvalidFilter, http.StatusBadRequest)
+			return
+		}
+
+		page, err := adminSrv.GetSolves(GetCtx(ctx), filter)
+		if err != nil {
+			adminError(ctx, logger, err, "get solves err")
+			return
+		}
+		if len(page.NextCursor) > 0 {
+			ctx.Response.Header.Set("X-Next-Cursor", page.NextCursor)
+		}
+		if format != "csv" {
+			adminWrite(ctx, http.StatusOK, page)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
+		ctx.SetContentType("text/csv; charset=utf-8")
+		w := csv.NewWriter(ctx.Response.BodyWriter())
+		w.Write([]string{"id", "team_id", "team_name", "task_id", "task_name", "created_at", "freeze"})
+		for _, solve := range page.Solves {
+			w.Write([]string{
+				strconv.Itoa(solve.ID),
+				strconv.Itoa(solve.TeamID),
+				csvCell(solve.TeamName),
+				strconv.Itoa(solve.TaskID),
+				csvCell(solve.TaskName),
+				solve.CreatedAt.UTC().Format(time.RFC3339),
+				strconv.FormatBool(solve.Freeze),
+			})
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestCsvCell(t *testing.T) {
+	for value, want := range map[string]string{
+		"":                  "",
+		"team":              "team",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-1":                "'-1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\t=1":              "'\t=1",
+		"a=b":               "a=b",
+	} {
+		if got := csvCell(value); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/log"
+	"ctfplatform/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/valyala/fasthttp"
+	"net/http"
+	"time"
+)
+
+const (
+	healthOk   = "ok"
+	healthFail = "fail"
+)
+
+type HealthCheck struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Health struct {
+	Status string                  `json:"status"`
+	Checks map[string]*HealthCheck `json:"checks,omitempty"`
+}
+
+type healthCheckFunc func(ctx context.Context) error
+
+func runHealthCheck(ctx context.Context, check healthCheckFunc) *HealthCheck {
+	now := time.Now()
+	err := check(ctx)
+	out := &HealthCheck{
+		Status:    healthOk,
+		LatencyMs: float64(time.Since(now).Microseconds()) / 1000,
+	}
+	if err != nil {
+		out.Status = healthFail
+		out.Error = err.Error()
+	}
+	return out
+}
+
+func writeHealth(ctx *fasthttp.RequestCtx, health Health) {
+	ctx.SetContentType("application/json")
+	ctx.Response.Header.Set("Cache-Control", "no-store")
+	if health.Status != healthOk {
+		ctx.SetStatusCode(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(ctx.Response.BodyWriter()).Encode(health)
+}
+
+// handleHealthLive process is up and serving, it does not touch dependencies so db outage does not restart containers
+func handleHealthLive() fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		writeHealth(ctx, Health{Status: healthOk})
+	}
+}
+
+// handleHealthReady instance can take traffic, every check is run even when previous one failed
+func handleHealthReady(dbSrv *db.DatabaseInternal, taskSrv *models.TaskInternal) fasthttp.RequestHandler {
+	checks := map[string]healthCheckFunc{
+		"database": dbSrv.Ping,
+		"schema":   dbSrv.CheckSchema,
+		"flag_cache": func(ctx context.Context) error {
+			state := taskSrv.FlagsCacheState()
+			if state.ReloadedAt.IsZero() {
+				return errors.New("flags not loaded yet")
+			}
+			if age := time.Since(state.CheckedAt); age > config.Config.FlagCacheMaxAge {
+				return fmt.Errorf("flags not checked for %s", age.Truncate(time.Second))
+			}
+			return nil
+		},
+		"config": func(ctx context.Context) error {
+			return config.CheckTimes()
+		},
+	}
+
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		health := Health{
+			Status: healthOk,
+			Checks: make(map[string]*HealthCheck, len(checks)),
+		}
+		for name, check := range checks {
+			result := runHealthCheck(ctxReq, check)
+			if result.Status != healthOk {
+				health.Status = healthFail
+				log.Log.WithField("check", name).WithField("err", result.Error).Error("readiness check failed")
+			}
+			health.Checks[name] = result
+		}
+		writeHealth(ctx, health)
+	}
+}
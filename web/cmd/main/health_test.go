@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// readyChecks runs readiness handler and returns status code and failed checks
+func readyChecks(t *testing.T, h fasthttp.RequestHandler) (int, map[string]string) {
+	t.Helper()
+	ctx := &fasthttp.RequestCtx{}
+	h(ctx)
+	var health Health
+	if err := json.Unmarshal(ctx.Response.Body(), &health); err != nil {
+		t.Fatalf("health %q: %v", ctx.Response.Body(), err)
+	}
+	failed := make(map[string]string)
+	for name, check := range health.Checks {
+		if check.Status != healthOk {
+			failed[name] = check.Error
+		}
+	}
+	if (len(failed) == 0) != (health.Status == healthOk) {
+		t.Fatalf("status %s with failed checks %v", health.Status, failed)
+	}
+	return ctx.Response.StatusCode(), failed
+}
+
+func TestHealthReady(t *testing.T) {
+	saved := *config.Config
+	defer func() { *config.Config = saved }()
+	now := time.Now()
+	config.Config.StartCompetition = config.DateTimeParser(now.Add(-time.Hour))
+	config.Config.EndCompetition = config.DateTimeParser(now.Add(time.Hour))
+	config.Config.FreezeStartCompetition = config.Config.EndCompetition
+	config.Config.FreezeEndCompetition = config.Config.EndCompetition
+	config.Config.FlagCacheMaxAge = time.Minute
+
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbSrv.Close(context.Background())
+	if _, err := dbSrv.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	taskSrv := models.NewTaskDB(dbSrv)
+	h := handleHealthReady(dbSrv, taskSrv)
+
+	// flags are loaded by worker after start
+	status, failed := readyChecks(t, h)
+	if status != http.StatusServiceUnavailable || len(failed) != 1 || !strings.Contains(failed["flag_cache"], "not loaded") {
+		t.Fatalf("before flags load status %d, failed %v", status, failed)
+	}
+
+	if err := taskSrv.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if status, failed := readyChecks(t, h); status != http.StatusOK || len(failed) != 0 {
+		t.Fatalf("after flags load status %d, failed %v", status, failed)
+	}
+
+	// worker stopped checking version
+	config.Config.FlagCacheMaxAge = time.Millisecond
+	time.Sleep(10 * time.Millisecond)
+	status, failed = readyChecks(t, h)
+	if status != http.StatusServiceUnavailable || len(failed) != 1 || !strings.Contains(failed["flag_cache"], "not checked") {
+		t.Fatalf("stale flags status %d, failed %v", status, failed)
+	}
+	config.Config.FlagCacheMaxAge = time.Minute
+
+	config.Config.FreezeStartCompetition = config.DateTimeParser(now.Add(-2 * time.Hour))
+	status, failed = readyChecks(t, h)
+	if status != http.StatusServiceUnavailable || len(failed) != 1 || len(failed["config"]) == 0 {
+		t.Fatalf("freeze before start status %d, failed %v", status, failed)
+	}
+}
@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"context"
 	"ctfplatform/actions"
+	"ctfplatform/avatar"
+	"ctfplatform/captcha"
 	"ctfplatform/config"
 	"ctfplatform/db"
 	"ctfplatform/log"
+	"ctfplatform/mail"
+	"ctfplatform/metrics"
 	"ctfplatform/models"
 	"ctfplatform/rand"
 	"ctfplatform/sentry"
 	"ctfplatform/session"
+	"ctfplatform/storage"
+	"ctfplatform/tracing"
 	"database/sql"
 	"encoding/base64"
 	"encoding/json"
@@ -20,48 +26,38 @@ import (
 	"github.com/goware/emailx"
 	"github.com/sirupsen/logrus"
 	"github.com/valyala/fasthttp"
-	"image/png"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 	"unicode"
 )
 
-func VerifyCaptcha(ctx context.Context, secret string, token string) error {
-	formData := url.Values{
-		"secret":   {secret},
-		"response": {token},
-		//"remoteip": {userIP},
+// verifyCaptcha writes error response and returns false when request should not continue
+func verifyCaptcha(ctx *fasthttp.RequestCtx, captchaSrv captcha.Verifier, token string) bool {
+	logger := GetLogger(ctx)
+
+	ctxReq, cancel := context.WithTimeout(GetCtx(ctx), config.Config.CaptchaTimeout)
+	defer cancel()
+	err := captchaSrv.Verify(ctxReq, token, GetUserIP(ctx))
+	if errors.Is(err, captcha.ErrInvalid) {
+		logger.WithError(err).Warning("invalid captcha")
+		ctx.Error(HttpErrInvalidCaptcha, http.StatusBadRequest)
+		return false
+	} else if err != nil && config.Config.CaptchaFailOpen {
+		logger.WithError(err).Warning("captcha unavailable, fail open")
+	} else if err != nil {
+		logger.WithError(err).Error("captcha unavailable")
+		ctx.Error(HttpErrCaptchaUnavailable, http.StatusServiceUnavailable)
+		return false
 	}
-
-	req, err := http.NewRequest("POST", "https://www.google.com/recaptcha/api/siteverify", strings.NewReader(formData.Encode()))
-	if err != nil {
-		return err
-	}
-	req = req.WithContext(ctx)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	var result struct {
-		Success bool `json:"success"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return err
-	}
-
-	if !result.Success {
-		return errors.New("captcha invalid")
-	}
-
-	return nil
+	return true
 }
 
 // json input validators
@@ -92,26 +88,19 @@ func (u *AvatarData) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
-	if len(avatarPayload) > 200000 {
+	if len(avatarPayload) > config.Config.AvatarMaxBytes {
 		return fmt.Errorf("%v: %w", errors.New("size to big"), ErrInvalidAvatar)
 	}
 
-	reader := bytes.NewReader(avatarPayload)
-	im, err := png.DecodeConfig(reader)
-	if err != nil {
+	// re-encoded, metadata and anything appended to image is dropped
+	normalized, err := avatar.Normalize(avatarPayload, config.Config.AvatarSize)
+	if errors.Is(err, avatar.ErrInvalid) {
 		return fmt.Errorf("%v: %w", err, ErrInvalidAvatar)
+	} else if err != nil {
+		return err
 	}
 
-	if im.Width > 256 || im.Height > 256 || im.Height < 30 || im.Width < 30 {
-		return fmt.Errorf("%v: %w", errors.New("width or height to big"), ErrInvalidAvatar)
-	}
-
-	ratio := float64(im.Width) / float64(im.Height)
-	if !(0.70 <= ratio && ratio <= 1.35) {
-		return fmt.Errorf("%v: %w", fmt.Errorf("ratio invalid w=%d h=%d r=%f", im.Width, im.Height, ratio), ErrInvalidAvatar)
-	}
-
-	*u = avatarPayload
+	*u = normalized
 	return nil
 }
 
@@ -174,6 +163,7 @@ const (
 	HttpErrInvalidCountry            = "invalid_country"
 	HttpErrInvalidWebsite            = "invalid_website"
 	HttpErrInvalidCaptcha            = "invalid_captcha"
+	HttpErrCaptchaUnavailable        = "captcha_unavailable"
 	HttpErrInvalidFlag               = "invalid_flag"
 	HttpErrInvalidPasswordLength     = "invalid_password_length"
 	HttpErrInvalidCurrentPassword    = "invalid_current_password"
@@ -181,8 +171,15 @@ const (
 	HttpErrInternalError             = "internal_error"
 	HttpErrNotFound                  = "not_found"
 	HttpErrEmailOrNameAlreadyExists  = "email_or_name_already_exists"
+	HttpErrReservedTeamName          = "reserved_team_name"
 	HttpErrNotAuthorize              = "not_authorize"
 	HttpErrAlreadySolved             = "already_solved"
+	HttpErrTeamNotActive             = "team_not_active"
+	HttpErrTeamBanned                = "team_banned"
+	HttpErrTeamDisqualified          = "team_disqualified"
+	HttpErrInvalidToken              = "invalid_token"
+	HttpErrInvalidTokenScope         = "invalid_token_scope"
+	HttpErrTooManyTokens             = "too_many_tokens"
 )
 
 func isASCII(s string) bool {
@@ -194,7 +191,7 @@ func isASCII(s string) bool {
 	return true
 }
 
-func handleRegister(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+func handleRegister(mainSrv *actions.MainInternal, captchaSrv captcha.Verifier) fasthttp.RequestHandler {
 	type request struct {
 		Name     string    `json:"name"`
 		Email    EmailData `json:"email"`
@@ -230,14 +227,7 @@ func handleRegister(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 			return
 		}
 
-		ctxReqShort, cancel := context.WithTimeout(ctxReq, time.Second)
-		defer cancel()
-		if err := VerifyCaptcha(ctxReqShort, config.Config.CaptchaSecret, input.Captcha); err == context.DeadlineExceeded {
-			// fallback
-			logger.WithError(err).Warning("captcha timeout")
-		} else if err != nil {
-			logger.WithError(err).Warning("invalid captcha")
-			ctx.Error(HttpErrInvalidCaptcha, http.StatusBadRequest)
+		if !verifyCaptcha(ctx, captchaSrv, input.Captcha) {
 			return
 		}
 
@@ -283,6 +273,13 @@ func handleRegister(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 			}).WithError(err).Warning("team duplicated")
 			ctx.Error(HttpErrEmailOrNameAlreadyExists, http.StatusBadRequest)
 			return
+		} else if err == actions.ReservedTeamName {
+			logger.WithFields(logrus.Fields{
+				"team_name": teamInput.Name,
+				"email": teamInput.Email,
+			}).WithError(err).Warning("team name reserved")
+			ctx.Error(HttpErrReservedTeamName, http.StatusBadRequest)
+			return
 		} else if err != nil {
 			logger.WithFields(logrus.Fields{
 				"team_name": teamInput.Name,
@@ -294,6 +291,7 @@ func handleRegister(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 		//if err := teamInput.SetAvatar(input.AvatarPath); err != nil {
 		//	log.Log.WithError(err).Error("avatar set err")
 		//}
+		metrics.Registrations.Inc()
 
 		ctx.SetStatusCode(http.StatusCreated)
 	}
@@ -312,8 +310,23 @@ func handleTeamAvatar(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 		}
 		filepath = filepath[1:]
 
-		avatarPayload, err := mainSrv.GetTeamDB().GetAvatar(ctxReq, filepath)
-		if err == sql.ErrNoRows {
+		if teamID, ok := avatar.ParseIdenticonPath(filepath); ok {
+			avatarPayload, err := avatar.Identicon(teamID, config.Config.AvatarSize)
+			if err != nil {
+				logger.WithError(err).Error("identicon err")
+				ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+				return
+			}
+			// the same id always renders the same image
+			ctx.SetStatusCode(http.StatusOK)
+			ctx.Response.Header.Set("Cache-Control", "public, max-age=86400")
+			ctx.Response.Header.Set("Content-Type", "image/png")
+			ctx.Response.SetBodyRaw(avatarPayload)
+			return
+		}
+
+		avatarPayload, err := mainSrv.GetAvatarStorage().Get(ctxReq, filepath)
+		if errors.Is(err, storage.ErrNotFound) {
 			logger.WithField("file", filepath).Warning("avatar not found")
 			ctx.Error(HttpErrNotFound, http.StatusNotFound)
 			return
@@ -332,9 +345,6 @@ func handleTeamAvatar(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 
 func handleTeamUpdate(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 	type request struct {
-		//CurrentPassword string `json:"current_password"`
-		//NewPassword     string `json:"new_password"`
-
 		Country CountryData `json:"country"`
 		Avatar  AvatarData  `json:"avatar"`
 
@@ -379,38 +389,33 @@ func handleTeamUpdate(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 		teamData.Website = input.Website
 		teamData.Affiliation = input.Affiliation
 
-		if len(input.Avatar) > 0 {
-			if err := teamData.SetAvatar(ctxReq, mainSrv.GetTeamDB(), input.Avatar); err != nil {
+		oldAvatar := teamData.AvatarPath
+		if len(input.Avatar) > 0 && config.Config.AvatarModeration {
+			// current avatar stays public until admin approves the new one
+			if err := mainSrv.SubmitAvatar(ctxReq, teamData.ID, input.Avatar); err != nil {
+				logger.WithError(err).Error("avatar submit err")
+				ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+				return
+			}
+		} else if len(input.Avatar) > 0 {
+			if err := teamData.SetAvatar(ctxReq, mainSrv.GetAvatarStorage(), input.Avatar); err != nil {
 				logger.WithError(err).Error("avatar set err")
 			}
 		}
 
-		//if len(input.CurrentPassword) > 0 || len(input.NewPassword) > 0 {
-		//	if len(input.NewPassword) < 8 {
-		//		log.Log.WithError(err).Warning("password invalid length")
-		//		ctx.Error(HttpErrInvalidPasswordLength, http.StatusBadRequest)
-		//		return
-		//	}
-		//
-		//	if !teamData.EqualPassword(input.CurrentPassword) {
-		//		log.Log.WithError(err).Warning("invalid current password")
-		//		ctx.Error(HttpErrInvalidCurrentPassword, http.StatusUnprocessableEntity)
-		//		return
-		//	}
-		//
-		//	if err := teamData.SetPassword(input.NewPassword); err != nil {
-		//		log.Log.WithError(err).Error("password set err")
-		//		ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
-		//		return
-		//	}
-		//}
-
 		err = mainSrv.GetTeamDB().UpdateTeam(ctxReq, *teamData)
 		if err != nil {
 			logger.WithError(err).Error("get team data err")
+			if teamData.AvatarPath != oldAvatar {
+				// team still points to old avatar, new one is not referenced
+				mainSrv.DeleteAvatar(ctxReq, teamData.AvatarPath)
+			}
 			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
 			return
 		}
+		if teamData.AvatarPath != oldAvatar {
+			mainSrv.DeleteAvatar(ctxReq, oldAvatar)
+		}
 
 		ctx.SetStatusCode(http.StatusOK)
 	}
@@ -418,7 +423,9 @@ func handleTeamUpdate(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 
 func handleLogout(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
 		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
 
 		if !bytes.Equal(ctx.PostBody(), []byte("{}")) {
 			logger.Warning("invalid json")
@@ -426,6 +433,22 @@ func handleLogout(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 			return
 		}
 
+		if sessionData.SessionID != 0 {
+			if err := mainSrv.RevokeSession(ctxReq, sessionData.TeamID, sessionData.SessionID); err != nil && err != actions.SessionNotFound {
+				logger.WithError(err).Error("revoke session err")
+				ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+				return
+			}
+		} else {
+			// old session has no row to revoke, only new session version invalidates it,
+			// it logs out other sessions of team too
+			if _, err := mainSrv.RevokeSessions(ctxReq, sessionData.TeamID, 0); err != nil {
+				logger.WithError(err).Error("revoke sessions err")
+				ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+				return
+			}
+		}
+
 		cookieData := fasthttp.AcquireCookie()
 		cookieData.SetExpire(fasthttp.CookieExpireDelete)
 		cookieData.SetPath("/")
@@ -439,7 +462,7 @@ func handleLogout(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 	}
 }
 
-func handleLogin(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+func handleLogin(mainSrv *actions.MainInternal, captchaSrv captcha.Verifier) fasthttp.RequestHandler {
 	type request struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
@@ -456,13 +479,7 @@ func handleLogin(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 			return
 		}
 
-		ctxReqShort, cancel := context.WithTimeout(ctxReq, time.Second)
-		defer cancel()
-		if err := VerifyCaptcha(ctxReqShort, config.Config.CaptchaSecret, input.Captcha); err == context.DeadlineExceeded {
-			logger.WithError(err).Warning("captcha timeout")
-		} else if err != nil {
-			logger.WithError(err).Warning("invalid captcha")
-			ctx.Error(HttpErrInvalidCaptcha, http.StatusBadRequest)
+		if !verifyCaptcha(ctx, captchaSrv, input.Captcha) {
 			return
 		}
 
@@ -471,46 +488,456 @@ func handleLogin(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 			(&models.TeamXXX{Password: "dummy password"}).EqualPassword(input.Password) // dummy check
 
 			logger.WithField("email", input.Email).Warning("team not found login")
+			metrics.Logins.WithLabelValues(metrics.LoginInvalid).Inc()
 			ctx.Error(HttpErrInvalidPasswordOrUsername, http.StatusUnprocessableEntity)
 			return
 		} else if err != nil {
 			logger.WithError(err).Error("get team login")
+			metrics.Logins.WithLabelValues(metrics.LoginError).Inc()
 			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
 			return
 		}
 
 		if !teamData.EqualPassword(input.Password) {
 			logger.WithField("email", input.Email).Warning("invalid password")
+			metrics.Logins.WithLabelValues(metrics.LoginInvalid).Inc()
 			ctx.Error(HttpErrInvalidPasswordOrUsername, http.StatusUnprocessableEntity)
 			return
 		}
 
-		sessionData := &SessionPermission{
-			TeamID: teamData.ID,
+		if err := actions.CheckTeamCanLogin(teamData); err == actions.TeamBanned {
+			logger.WithField("team_id", teamData.ID).Warning("team banned login")
+			metrics.Logins.WithLabelValues(metrics.LoginBlocked).Inc()
+			ctx.Error(HttpErrTeamBanned, http.StatusForbidden)
+			return
+		} else if err == actions.TeamNotActive {
+			logger.WithField("team_id", teamData.ID).Warning("team not active login")
+			metrics.Logins.WithLabelValues(metrics.LoginBlocked).Inc()
+			ctx.Error(HttpErrTeamNotActive, http.StatusForbidden)
+			return
 		}
-		sessionDataEncoded, err := session.MarshalSession(config.Config.AesSecretKey, config.Config.HmacSecretKey, sessionData)
+
+		sessionID, err := mainSrv.NewSession(ctxReq, teamData.ID, GetUserIP(ctx), string(ctx.UserAgent()))
 		if err != nil {
+			logger.WithError(err).WithField("team_id", teamData.ID).Error("cannot create session")
+			metrics.Logins.WithLabelValues(metrics.LoginError).Inc()
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		sessionData := &SessionPermission{
+			TeamID:    teamData.ID,
+			SessionID: sessionID,
+			Version:   teamData.SessionVersion,
+		}
+		if err := setSessionCookie(ctx, sessionData); err != nil {
 			logger.WithError(err).WithField("team_id", teamData.ID).Error("cannot encode session")
 			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
 			return
 		}
 
+		metrics.Logins.WithLabelValues(metrics.LoginOk).Inc()
+		json.NewEncoder(ctx.Response.BodyWriter()).Encode(actions.TeamData{
+			ID: teamData.ID,
+		})
+	}
+}
+
+func handleVerifyEmail(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+
+		token := string(ctx.QueryArgs().Peek("token"))
+		teamID, err := mainSrv.VerifyEmail(ctxReq, token)
+		if err == actions.InvalidVerificationToken {
+			logger.WithError(err).Warning("invalid verification token")
+			ctx.Error(HttpErrInvalidToken, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("verify email err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		logger.WithField("team_id", teamID).Info("team email verified")
+		ctx.Redirect(strings.TrimRight(config.Config.PublicUrl, "/")+"/login", http.StatusFound)
+	}
+}
+
+func handleVerifyEmailResend(mainSrv *actions.MainInternal, captchaSrv captcha.Verifier) fasthttp.RequestHandler {
+	type request struct {
+		Email   string `json:"email"`
+		Captcha string `json:"captcha"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if !verifyCaptcha(ctx, captchaSrv, input.Captcha) {
+			return
+		}
+
+		// always the same response, do not leak which emails are registered
+		if err := mainSrv.ResendVerificationEmail(ctxReq, input.Email); err == actions.TeamNotFound {
+			logger.WithField("email", input.Email).Warning("team not found resend verification")
+		} else if err != nil {
+			logger.WithError(err).Error("resend verification err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+func handleForgotPassword(mainSrv *actions.MainInternal, captchaSrv captcha.Verifier) fasthttp.RequestHandler {
+	type request struct {
+		Email   string `json:"email"`
+		Captcha string `json:"captcha"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if !verifyCaptcha(ctx, captchaSrv, input.Captcha) {
+			return
+		}
+
+		// unknown email gets the same response
+		if err := mainSrv.ForgotPassword(ctxReq, input.Email); err != nil {
+			logger.WithError(err).Error("forgot password err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+func handleResetPassword(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	type request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.Password) < 8 {
+			logger.Warning("password invalid length")
+			ctx.Error(HttpErrInvalidPasswordLength, http.StatusBadRequest)
+			return
+		}
+
+		teamID, err := mainSrv.ResetPassword(ctxReq, input.Token, input.Password)
+		if err == actions.InvalidPasswordResetToken {
+			logger.WithError(err).Warning("invalid password reset token")
+			ctx.Error(HttpErrInvalidToken, http.StatusBadRequest)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("reset password err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		logger.WithField("team_id", teamID).Info("password reset")
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+func handlePasswordChange(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	type request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if len(input.NewPassword) < 8 {
+			logger.Warning("password invalid length")
+			ctx.Error(HttpErrInvalidPasswordLength, http.StatusBadRequest)
+			return
+		}
+
+		version, err := mainSrv.ChangePassword(ctxReq, sessionData.TeamID, sessionData.SessionID, input.CurrentPassword, input.NewPassword)
+		if err == actions.InvalidCurrentPassword {
+			logger.WithError(err).Warning("invalid current password")
+			ctx.Error(HttpErrInvalidCurrentPassword, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("change password err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		// other sessions are invalid now, keep this one logged in
+		sessionData.Version = version
+		if err := setSessionCookie(ctx, sessionData); err != nil {
+			logger.WithError(err).Error("cannot encode session")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+func handleTeamExport(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		export, err := mainSrv.ExportTeam(ctxReq, sessionData.TeamID)
+		if err != nil {
+			logger.WithError(err).Error("export team err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		logger.WithField("team_id", sessionData.TeamID).Info("team data exported")
+		ctx.SetContentType("application/json")
+		ctx.Response.Header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="team-%d.json"`, sessionData.TeamID))
+		enc := json.NewEncoder(ctx.Response.BodyWriter())
+		enc.SetIndent("", "  ")
+		enc.Encode(export)
+	}
+}
+
+func handleTeamDelete(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	type request struct {
+		Password string `json:"password"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if err := mainSrv.DeleteAccount(ctxReq, sessionData.TeamID, input.Password); err == actions.InvalidCurrentPassword {
+			logger.WithError(err).Warning("invalid current password")
+			ctx.Error(HttpErrInvalidCurrentPassword, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("delete account err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+		logger.WithField("team_id", sessionData.TeamID).Info("team deleted by itself")
+
 		cookieData := fasthttp.AcquireCookie()
+		cookieData.SetExpire(fasthttp.CookieExpireDelete)
 		cookieData.SetPath("/")
-		cookieData.SetMaxAge(int((time.Hour * 24 * 360).Seconds()))
 		cookieData.SetKey("session")
 		cookieData.SetHTTPOnly(true)
-		if config.Config.EnableSecureCookies {
-			cookieData.SetSecure(true)
-		}
-		cookieData.SetSameSite(fasthttp.CookieSameSiteLaxMode)
-		cookieData.SetValueBytes(sessionDataEncoded)
+		cookieData.SetValue("")
 		ctx.Response.Header.SetCookie(cookieData)
 		fasthttp.ReleaseCookie(cookieData)
 
-		json.NewEncoder(ctx.Response.BodyWriter()).Encode(actions.TeamData{
-			ID: teamData.ID,
-		})
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+func handleSessions(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		sessions, err := mainSrv.GetSessions(ctxReq, sessionData.TeamID, sessionData.SessionID)
+		if err != nil {
+			logger.WithError(err).Error("get sessions err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(ctx.Response.BodyWriter()).Encode(sessions)
+	}
+}
+
+func handleSessionRevoke(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	type request struct {
+		ID int `json:"id"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if err := mainSrv.RevokeSession(ctxReq, sessionData.TeamID, input.ID); err == actions.SessionNotFound {
+			logger.WithField("session_id", input.ID).Warning("session not found")
+			ctx.Error(HttpErrNotFound, http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("revoke session err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+// handleSessionRevokeAll logs out everywhere except current session
+func handleSessionRevokeAll(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		if !bytes.Equal(ctx.PostBody(), []byte("{}")) {
+			logger.Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		version, err := mainSrv.RevokeSessions(ctxReq, sessionData.TeamID, sessionData.SessionID)
+		if err != nil {
+			logger.WithError(err).Error("revoke sessions err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		sessionData.Version = version
+		if err := setSessionCookie(ctx, sessionData); err != nil {
+			logger.WithError(err).Error("cannot encode session")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
+	}
+}
+
+func handleTokens(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		tokens, err := mainSrv.GetTokens(ctxReq, sessionData.TeamID)
+		if err != nil {
+			logger.WithError(err).Error("get tokens err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(ctx.Response.BodyWriter()).Encode(tokens)
+	}
+}
+
+func handleTokenCreate(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	type request struct {
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+		// column length is in characters, cutting bytes could split multibyte one
+		name := strings.TrimSpace(input.Name)
+		if runes := []rune(name); len(runes) > 64 {
+			name = string(runes[:64])
+		}
+
+		token, err := mainSrv.CreateToken(ctxReq, sessionData.TeamID, name, input.Scope)
+		if err == actions.InvalidTokenScope {
+			logger.WithField("scope", input.Scope).Warning("invalid token scope")
+			ctx.Error(HttpErrInvalidTokenScope, http.StatusBadRequest)
+			return
+		} else if err == actions.TooManyTokens {
+			logger.Warning("too many tokens")
+			ctx.Error(HttpErrTooManyTokens, http.StatusUnprocessableEntity)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("create token err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		logger.WithField("token_id", token.ID).Info("token created")
+		ctx.SetStatusCode(http.StatusCreated)
+		json.NewEncoder(ctx.Response.BodyWriter()).Encode(token)
+	}
+}
+
+func handleTokenRevoke(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
+	type request struct {
+		ID int `json:"id"`
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		ctxReq := GetCtx(ctx)
+		logger := GetLogger(ctx)
+		sessionData := GetSession(ctx)
+
+		input := request{}
+		if err := json.Unmarshal(ctx.PostBody(), &input); err != nil {
+			logger.WithError(err).Warning("invalid json")
+			ctx.Error(HttpErrInvalidJson, http.StatusBadRequest)
+			return
+		}
+
+		if err := mainSrv.RevokeToken(ctxReq, sessionData.TeamID, input.ID); err == actions.TokenNotFound {
+			logger.WithField("token_id", input.ID).Warning("token not found")
+			ctx.Error(HttpErrNotFound, http.StatusNotFound)
+			return
+		} else if err != nil {
+			logger.WithError(err).Error("revoke token err")
+			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
+			return
+		}
+
+		ctx.SetStatusCode(http.StatusOK)
 	}
 }
 
@@ -682,25 +1109,123 @@ func handleFlagSubmit(mainSrv *actions.MainInternal) fasthttp.RequestHandler {
 
 		if err := mainSrv.Solve(ctxReq, sessionData.TeamID, input.Flag); err == actions.InvalidFlag {
 			logger.WithField("flag", input.Flag).WithError(err).Warning("invalid flag")
+			metrics.FlagSubmissions.WithLabelValues(metrics.FlagInvalid).Inc()
 			ctx.Error(HttpErrInvalidFlag, http.StatusUnprocessableEntity)
 			return
 		} else if err == actions.AlreadySolved {
 			logger.WithField("flag", input.Flag).WithError(err).Warning("already solved")
+			metrics.FlagSubmissions.WithLabelValues(metrics.FlagAlreadySolved).Inc()
 			ctx.Error(HttpErrAlreadySolved, http.StatusUnprocessableEntity)
 			return
+		} else if err == actions.TeamNotActive {
+			logger.WithError(err).Warning("team not active submit")
+			metrics.FlagSubmissions.WithLabelValues(metrics.FlagRejected).Inc()
+			ctx.Error(HttpErrTeamNotActive, http.StatusForbidden)
+			return
+		} else if err == actions.TeamBanned {
+			logger.WithError(err).Warning("team banned submit")
+			metrics.FlagSubmissions.WithLabelValues(metrics.FlagRejected).Inc()
+			ctx.Error(HttpErrTeamBanned, http.StatusForbidden)
+			return
+		} else if err == actions.TeamDisqualified {
+			logger.WithError(err).Warning("team disqualified submit")
+			metrics.FlagSubmissions.WithLabelValues(metrics.FlagRejected).Inc()
+			ctx.Error(HttpErrTeamDisqualified, http.StatusForbidden)
+			return
 		} else if err != nil {
 			logger.WithField("flag", input.Flag).WithError(err).Error("save solve err")
+			metrics.FlagSubmissions.WithLabelValues(metrics.FlagError).Inc()
 			ctx.Error(HttpErrInternalError, http.StatusInternalServerError)
 			return
 		}
 
+		metrics.FlagSubmissions.WithLabelValues(metrics.FlagCorrect).Inc()
 		ctx.Response.SetStatusCode(http.StatusOK)
 	}
 }
 
 // middleware
 type SessionPermission struct {
-	TeamID int `json:"team_id"`
+	TeamID    int `json:"team_id"`
+	SessionID int `json:"session_id,omitempty"`
+	Version   int `json:"version,omitempty"`
+
+	// only set when authorized by api token, never stored in cookie
+	TokenID int `json:"-"`
+}
+
+var sessionKeyring *session.Keyring
+
+func newSessionKeyring() (*session.Keyring, error) {
+	keys, err := session.ParseKeys(config.Config.SessionKeys)
+	if err != nil {
+		return nil, err
+	}
+	// key derived from old secrets encrypted sessions before SESSION_KEYS was set, it is kept
+	// for decryption so the first rotation does not log everyone out
+	hasDefault := false
+	for _, key := range keys {
+		hasDefault = hasDefault || key.ID == "default"
+	}
+	// rotated secrets stay for decryption, not set one of them means it was not rotated
+	previousAesKey, previousHmacKey := config.Config.PreviousAesSecretKey, config.Config.PreviousHmacSecretKey
+	rotated := len(previousAesKey) > 0 || len(previousHmacKey) > 0
+	if len(previousAesKey) == 0 {
+		previousAesKey = config.Config.AesSecretKey
+	}
+	if len(previousHmacKey) == 0 {
+		previousHmacKey = config.Config.HmacSecretKey
+	}
+	if !hasDefault {
+		keys = append(keys, session.DeriveKey("default", config.Config.AesSecretKey, config.Config.HmacSecretKey))
+		if rotated {
+			keys = append(keys, session.DeriveKey("default", previousAesKey, previousHmacKey))
+		}
+	}
+	keyring, err := session.NewKeyring(keys)
+	if err != nil {
+		return nil, err
+	}
+	if config.Config.AcceptLegacySessions {
+		until := time.Time(config.Config.LegacySessionsUntil)
+		if until.IsZero() {
+			until = time.Time(config.Config.EndCompetition)
+		}
+		keyring.AddLegacy(config.Config.AesSecretKey, config.Config.HmacSecretKey, until)
+		if rotated {
+			keyring.AddLegacy(previousAesKey, previousHmacKey, until)
+		}
+	}
+	return keyring, nil
+}
+
+// authSrv validates sessions and api tokens in TimeoutMiddleware, set in run
+var authSrv *actions.MainInternal
+
+func setSessionCookie(ctx *fasthttp.RequestCtx, sessionData *SessionPermission) error {
+	return setSessionCookieUntil(ctx, sessionData, time.Now().Add(config.Config.SessionTtl))
+}
+
+// setSessionCookieUntil issues session again without extending it
+func setSessionCookieUntil(ctx *fasthttp.RequestCtx, sessionData *SessionPermission, expiresAt time.Time) error {
+	sessionDataEncoded, err := sessionKeyring.MarshalUntil(sessionData, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	cookieData := fasthttp.AcquireCookie()
+	cookieData.SetPath("/")
+	cookieData.SetMaxAge(int(time.Until(expiresAt).Seconds()))
+	cookieData.SetKey("session")
+	cookieData.SetHTTPOnly(true)
+	if config.Config.EnableSecureCookies {
+		cookieData.SetSecure(true)
+	}
+	cookieData.SetSameSite(fasthttp.CookieSameSiteLaxMode)
+	cookieData.SetValueBytes(sessionDataEncoded)
+	ctx.Response.Header.SetCookie(cookieData)
+	fasthttp.ReleaseCookie(cookieData)
+	return nil
 }
 
 func GetSession(ctx *fasthttp.RequestCtx) *SessionPermission {
@@ -731,6 +1256,43 @@ func GetUserIP(ctx *fasthttp.RequestCtx) string {
 	return string(ctx.Request.Header.Peek("X-Real-IP"))
 }
 
+// GetRoute returns route pattern set by routeRouter
+func GetRoute(ctx *fasthttp.RequestCtx) string {
+	route, ok := ctx.UserValue("_route").(string)
+	if !ok {
+		return "unknown"
+	}
+	return route
+}
+
+// routeRouter remembers route pattern of handler, raw path would give metric label per id
+type routeRouter struct {
+	*router.Router
+}
+
+func (r routeRouter) handle(path string, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue("_route", path)
+		h(ctx)
+	}
+}
+
+func (r routeRouter) GET(path string, h fasthttp.RequestHandler) {
+	r.Router.GET(path, r.handle(path, h))
+}
+
+func (r routeRouter) POST(path string, h fasthttp.RequestHandler) {
+	r.Router.POST(path, r.handle(path, h))
+}
+
+func (r routeRouter) PUT(path string, h fasthttp.RequestHandler) {
+	r.Router.PUT(path, r.handle(path, h))
+}
+
+func (r routeRouter) DELETE(path string, h fasthttp.RequestHandler) {
+	r.Router.DELETE(path, r.handle(path, h))
+}
+
 func goRecover(f func(), recovered chan<- interface{}) {
 	go func() {
 		done := false // just to handle panic(nil) https://github.com/golang/go/issues/25448
@@ -744,11 +1306,82 @@ func goRecover(f func(), recovered chan<- interface{}) {
 	}()
 }
 
+var errTokenScope = errors.New("token scope not allowed")
+
+const authScopeSession = "session"
+
+// authorize checks session cookie, or api token when scope is one of token scopes.
+// Returned time is set when session should be issued again, it is session expiry.
+func authorize(ctx *fasthttp.RequestCtx, ctxReq context.Context, scope string) (*SessionPermission, time.Time, error) {
+	authHeader := ctx.Request.Header.Peek("Authorization")
+	if len(authHeader) > 0 {
+		if !bytes.HasPrefix(authHeader, []byte("Bearer ")) {
+			return nil, time.Time{}, errors.New("invalid authorization header")
+		}
+		tokenData, err := authSrv.AuthenticateToken(ctxReq, string(authHeader[len("Bearer "):]))
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if scope == authScopeSession || !tokenData.HasScope(scope) {
+			return nil, time.Time{}, errTokenScope
+		}
+		return &SessionPermission{
+			TeamID:  tokenData.TeamID,
+			TokenID: tokenData.ID,
+		}, time.Time{}, nil
+	}
+
+	sessionData := ctx.Request.Header.Cookie("session")
+	if len(sessionData) == 0 {
+		return nil, time.Time{}, errors.New("session not exists")
+	}
+
+	var sessionOut *SessionPermission
+	expiresAt, stale, err := sessionKeyring.Unmarshal(sessionData, &sessionOut)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("session decode: %w", err)
+	}
+	if err := authSrv.ValidateSession(ctxReq, sessionOut.TeamID, sessionOut.SessionID, sessionOut.Version); err != nil {
+		return nil, time.Time{}, err
+	}
+	if !stale {
+		expiresAt = time.Time{}
+	}
+	return sessionOut, expiresAt, nil
+}
+
+// TimeoutMiddleware with needAuth accepts only session cookie
 func TimeoutMiddleware(needAuth bool, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	if needAuth {
+		return timeoutMiddleware(authScopeSession, h)
+	}
+	return timeoutMiddleware("", h)
+}
+
+// TokenMiddleware accepts session cookie or api token with given scope
+func TokenMiddleware(scope string, h fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return timeoutMiddleware(scope, h)
+}
+
+func timeoutMiddleware(authScope string, h fasthttp.RequestHandler) fasthttp.RequestHandler {
 	return func(ctx *fasthttp.RequestCtx) {
 		now := time.Now()
+		route := GetRoute(ctx)
 		ctxReq, cancel := context.WithTimeout(context.Background(), config.Config.RequestTimeout)
 		defer cancel()
+		ctxReq, span := tracing.StartRequest(ctxReq, ctx, route)
+		// after timeout handler goroutine can still write response, so status is kept aside
+		var status int
+		defer func() {
+			if status == 0 {
+				// handler was not started (authorize failed), response is not shared
+				status = ctx.Response.StatusCode()
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			span.End()
+			metrics.HttpRequests.WithLabelValues(route, string(ctx.Method()), strconv.Itoa(status)).Inc()
+			metrics.HttpRequestDuration.WithLabelValues(route).Observe(time.Since(now).Seconds())
+		}()
 		done := make(chan struct{})
 		recovered := make(chan interface{})
 
@@ -757,21 +1390,39 @@ func TimeoutMiddleware(needAuth bool, h fasthttp.RequestHandler) fasthttp.Reques
 			"user_ip":    GetUserIP(ctx),
 			"url":        string(ctx.Request.RequestURI()),
 		})
+		if span.SpanContext().IsValid() {
+			logger = logger.WithField("trace_id", span.SpanContext().TraceID().String())
+		}
 
-		if needAuth {
-			sessionData := ctx.Request.Header.Cookie("session")
-			if len(sessionData) == 0 {
-				ctx.Error(HttpErrNotAuthorize, http.StatusUnauthorized)
+		if len(authScope) > 0 {
+			sessionOut, staleUntil, err := authorize(ctx, ctxReq, authScope)
+			if err == errTokenScope {
+				logger.WithError(err).Warning("token scope err")
+				ctx.Error(HttpErrInvalidTokenScope, http.StatusForbidden)
 				return
-			}
-
-			var sessionOut *SessionPermission
-			if err := session.UnmarshalSession(config.Config.AesSecretKey, config.Config.HmacSecretKey, sessionData, &sessionOut); err != nil {
-				logger.WithError(err).Warning("session decode err")
+			} else if err == actions.TeamBanned {
+				logger.WithError(err).Warning("authorize err")
+				ctx.Error(HttpErrTeamBanned, http.StatusForbidden)
+				return
+			} else if err == actions.TeamNotActive {
+				logger.WithError(err).Warning("authorize err")
+				ctx.Error(HttpErrTeamNotActive, http.StatusForbidden)
+				return
+			} else if err != nil {
+				logger.WithError(err).Warning("authorize err")
 				ctx.Error(HttpErrNotAuthorize, http.StatusUnauthorized)
 				return
 			}
 			logger = logger.WithField("team_id", sessionOut.TeamID)
+			if sessionOut.TokenID != 0 {
+				logger = logger.WithField("token_id", sessionOut.TokenID)
+			}
+			if !staleUntil.IsZero() {
+				// old format or rotated key, handler can still overwrite cookie
+				if err := setSessionCookieUntil(ctx, sessionOut, staleUntil); err != nil {
+					logger.WithError(err).Error("cannot encode session")
+				}
+			}
 			ctx.SetUserValue("_session", sessionOut)
 		}
 
@@ -789,24 +1440,61 @@ func TimeoutMiddleware(needAuth bool, h fasthttp.RequestHandler) fasthttp.Reques
 			logger.WithFields(logrus.Fields{
 				"duration": duration,
 			}).WithError(ctxReq.Err()).Error("request timeout")
-			ctx.TimeoutErrorWithCode("request_timeout", http.StatusServiceUnavailable)
+			metrics.HttpTimeouts.WithLabelValues(route).Inc()
+			span.SetStatus(codes.Error, "request timeout")
+			status = http.StatusServiceUnavailable
+			ctx.TimeoutErrorWithCode("request_timeout", status)
 		case <-done:
+			status = ctx.Response.StatusCode()
 			if duration.Seconds() > 1.0 {
 				logger.WithFields(logrus.Fields{
-					"status":   ctx.Response.StatusCode(),
+					"status":   status,
 					"duration": duration,
 				}).Warning("request bigger time")
 			}
 			logger.WithFields(logrus.Fields{
-				"status":   ctx.Response.StatusCode(),
+				"status":   status,
 				"duration": duration,
 			}).Info("request ok")
 		case err := <-recovered:
+			status = http.StatusInternalServerError
+			ctx.SetStatusCode(status)
+			span.SetStatus(codes.Error, fmt.Sprint(err))
 			panic(err)
 		}
 	}
 }
 
+func migrate(dbSrv *db.DatabaseInternal) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if config.Config.MigrateOnStart {
+		applied, err := dbSrv.Migrate(ctx)
+		for _, m := range applied {
+			log.Log.WithField("version", m.Version).WithField("name", m.Name).Info("migration applied")
+		}
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+	return dbSrv.CheckSchema(ctx)
+}
+
+// shutdownServer stops accepting connections and waits for in-flight requests until ctx expires
+func shutdownServer(ctx context.Context, s *fasthttp.Server) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Shutdown()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func run() error {
 	if len(config.Config.SentryDsn) > 0 {
 		hook, err := sentry.NewSentryHook(config.Config.SentryDsn)
@@ -817,21 +1505,97 @@ func run() error {
 	}
 	log.Log.Info("server starting")
 
+	shutdownTracing, err := tracing.Init(context.Background(), config.Config.TracingExporter, config.Config.TracingSampleRatio)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Log.WithError(err).Error("tracing shutdown")
+		}
+	}()
+
 	dbSrv, err := db.NewDB(config.Config.MysqlDsn)
 	if err != nil {
 		return err
 	}
+	if err := migrate(dbSrv); err != nil {
+		return err
+	}
+
+	mailer, err := mail.NewMailer(config.Config.MailBackend, config.Config.SmtpAddr, config.Config.SmtpUsername, config.Config.SmtpPassword, config.Config.MailFrom)
+	if err != nil {
+		return err
+	}
+	if mailer == nil {
+		if config.Config.RequireEmailVerification {
+			return errors.New("email verification requires mail backend")
+		}
+		log.Log.Warning("mail backend not configured")
+	}
+
+	captchaSrv, err := captcha.NewVerifier(config.Config.CaptchaProvider, config.Config.CaptchaSecret, config.Config.CaptchaVerifyUrl, config.Config.CaptchaMinScore)
+	if err != nil {
+		return err
+	}
+	if config.Config.CaptchaProvider == captcha.ProviderDisabled {
+		log.Log.Warning("captcha disabled")
+	}
+
+	avatarStorage, err := storage.NewStorageFromConfig(config.Config.AvatarStorage, dbSrv)
+	if err != nil {
+		return err
+	}
 
 	teamSrv := models.NewTeamDB(dbSrv)
 	taskSrv := models.NewTaskDB(dbSrv)
 	auditSrv := models.NewAuditDB(dbSrv)
-	mainSrv := actions.NewRanking(taskSrv, teamSrv, auditSrv, dbSrv)
+	announcementSrv := models.NewAnnouncementDB(dbSrv)
+	mainSrv := actions.NewRanking(taskSrv, teamSrv, auditSrv, announcementSrv, mailer, avatarStorage, dbSrv)
+	adminSrv := actions.NewAdmin(mainSrv)
 
-	ctx := context.Background()
-	go taskSrv.Run(ctx)
+	sessionKeyring, err = newSessionKeyring()
+	if err != nil {
+		return err
+	}
+	authSrv = mainSrv
+
+	// first SIGINT/SIGTERM starts graceful shutdown, second one kills process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		taskSrv.Run(ctx)
+	}()
+
+	var metricsSrv *http.Server
+	metrics.RegisterDBStats(dbSrv.Stats)
+	metrics.RegisterFlagCache(func() time.Time {
+		return taskSrv.FlagsCacheState().CheckedAt
+	})
+	if len(config.Config.MetricsListen) > 0 {
+		// separate listener, metrics should not be reachable through public nginx
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		metricsSrv = &http.Server{
+			Addr:    config.Config.MetricsListen,
+			Handler: mux,
+		}
+		go func() {
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Log.WithError(err).Error("metrics server closed with err")
+			}
+		}()
+	}
 
-	r := router.New()
+	r := routeRouter{router.New()}
 	r.PanicHandler = func(ctx *fasthttp.RequestCtx, err interface{}) {
+		metrics.HttpPanics.Inc()
 		log.Log.WithField("err", err).WithField("url", string(ctx.RequestURI())).Error("panic from http handler")
 		ctx.Error("internal_error", http.StatusInternalServerError)
 	}
@@ -845,34 +1609,103 @@ func run() error {
 	r.GET("/api/v1/teams", fasthttp.CompressHandler(TimeoutMiddleware(false, handleTeams(mainSrv))))
 	r.GET("/api/v1/team_info/:team_id", fasthttp.CompressHandler(TimeoutMiddleware(false, handleTeamInfo(mainSrv))))
 
-	r.POST("/api/v1/team/register", fasthttp.CompressHandler(TimeoutMiddleware(false, handleRegister(mainSrv))))
-	r.POST("/api/v1/team/login", fasthttp.CompressHandler(TimeoutMiddleware(false, handleLogin(mainSrv))))
+	r.POST("/api/v1/team/register", fasthttp.CompressHandler(TimeoutMiddleware(false, handleRegister(mainSrv, captchaSrv))))
+	r.POST("/api/v1/team/login", fasthttp.CompressHandler(TimeoutMiddleware(false, handleLogin(mainSrv, captchaSrv))))
 	r.POST("/api/v1/team/logout", fasthttp.CompressHandler(TimeoutMiddleware(true, handleLogout(mainSrv))))
+	r.GET("/api/v1/team/verify", fasthttp.CompressHandler(TimeoutMiddleware(false, handleVerifyEmail(mainSrv))))
+	r.POST("/api/v1/team/verify/resend", fasthttp.CompressHandler(TimeoutMiddleware(false, handleVerifyEmailResend(mainSrv, captchaSrv))))
+	r.POST("/api/v1/team/password/forgot", fasthttp.CompressHandler(TimeoutMiddleware(false, handleForgotPassword(mainSrv, captchaSrv))))
+	r.POST("/api/v1/team/password/reset", fasthttp.CompressHandler(TimeoutMiddleware(false, handleResetPassword(mainSrv))))
 
 	r.GET("/api/v1/team/avatar/*filepath", fasthttp.CompressHandler(TimeoutMiddleware(false, handleTeamAvatar(mainSrv))))
 
-	r.GET("/api/v1/team", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTeamMy(mainSrv))))
+	r.GET("/api/v1/team", fasthttp.CompressHandler(TokenMiddleware(models.TokenScopeRead, handleTeamMy(mainSrv))))
 	r.POST("/api/v1/team/settings", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTeamUpdate(mainSrv))))
-	r.POST("/api/v1/flag/submit", fasthttp.CompressHandler(TimeoutMiddleware(true, handleFlagSubmit(mainSrv))))
-
-	r.GET("/api/v1/healthcheck", func(ctx *fasthttp.RequestCtx) {
-		ctxReq, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
-		if err := dbSrv.Ping(ctxReq); err != nil {
-			log.Log.WithError(err).Error("healthcheck failed")
-			ctx.SetStatusCode(http.StatusInternalServerError)
-			return
-		}
-
-		log.Log.Info("healthcheck ok")
-		ctx.SetStatusCode(http.StatusOK)
-	})
+	r.POST("/api/v1/team/password", fasthttp.CompressHandler(TimeoutMiddleware(true, handlePasswordChange(mainSrv))))
+	r.GET("/api/v1/team/export", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTeamExport(mainSrv))))
+	r.POST("/api/v1/team/delete", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTeamDelete(mainSrv))))
+	r.GET("/api/v1/team/sessions", fasthttp.CompressHandler(TimeoutMiddleware(true, handleSessions(mainSrv))))
+	r.POST("/api/v1/team/sessions/revoke", fasthttp.CompressHandler(TimeoutMiddleware(true, handleSessionRevoke(mainSrv))))
+	r.POST("/api/v1/team/sessions/revoke_all", fasthttp.CompressHandler(TimeoutMiddleware(true, handleSessionRevokeAll(mainSrv))))
+	r.GET("/api/v1/team/tokens", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTokens(mainSrv))))
+	r.POST("/api/v1/team/tokens", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTokenCreate(mainSrv))))
+	r.POST("/api/v1/team/tokens/revoke", fasthttp.CompressHandler(TimeoutMiddleware(true, handleTokenRevoke(mainSrv))))
+	r.POST("/api/v1/flag/submit", fasthttp.CompressHandler(TokenMiddleware(models.TokenScopeSubmit, handleFlagSubmit(mainSrv))))
+
+	r.GET("/api/admin/v1/tasks", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTasks(adminSrv)))))
+	r.POST("/api/admin/v1/tasks", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTaskCreate(adminSrv)))))
+	r.GET("/api/admin/v1/tasks/:task_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTask(adminSrv)))))
+	r.PUT("/api/admin/v1/tasks/:task_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTaskUpdate(adminSrv)))))
+	r.DELETE("/api/admin/v1/tasks/:task_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTaskDelete(adminSrv)))))
+	r.GET("/api/admin/v1/tasks/:task_id/flags", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminFlags(adminSrv)))))
+	r.POST("/api/admin/v1/tasks/:task_id/flags", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminFlagCreate(adminSrv)))))
+	r.DELETE("/api/admin/v1/tasks/:task_id/flags/:flag_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminFlagDelete(adminSrv)))))
+	r.GET("/api/admin/v1/flag_cache", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminFlagCache(adminSrv)))))
+	r.POST("/api/admin/v1/flag_cache/reload", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminFlagCacheReload(adminSrv)))))
+
+	r.GET("/api/admin/v1/announcements", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAnnouncements(adminSrv)))))
+	r.POST("/api/admin/v1/announcements", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAnnouncementCreate(adminSrv)))))
+	r.PUT("/api/admin/v1/announcements/:announcement_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAnnouncementUpdate(adminSrv)))))
+	r.DELETE("/api/admin/v1/announcements/:announcement_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAnnouncementDelete(adminSrv)))))
+
+	r.GET("/api/admin/v1/teams", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeams(adminSrv)))))
+	r.POST("/api/admin/v1/teams", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeamCreate(adminSrv)))))
+	r.GET("/api/admin/v1/teams/:team_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeam(adminSrv)))))
+	r.PUT("/api/admin/v1/teams/:team_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeamUpdate(adminSrv)))))
+	r.DELETE("/api/admin/v1/teams/:team_id", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeamDelete(adminSrv)))))
+	r.POST("/api/admin/v1/teams/:team_id/sessions/revoke", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeamRevokeSessions(adminSrv)))))
+	r.POST("/api/admin/v1/teams/:team_id/state", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeamState(adminSrv)))))
+	r.GET("/api/admin/v1/teams/:team_id/moderation", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminTeamModerations(adminSrv)))))
+	r.GET("/api/admin/v1/solves", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminSolves(adminSrv)))))
+
+	r.GET("/api/admin/v1/avatars", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAvatars(adminSrv)))))
+	r.GET("/api/admin/v1/avatars/:moderation_id/image", TimeoutMiddleware(false, AdminMiddleware(handleAdminAvatarImage(adminSrv))))
+	r.POST("/api/admin/v1/avatars/:moderation_id/approve", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAvatarApprove(adminSrv)))))
+	r.POST("/api/admin/v1/avatars/:moderation_id/reject", fasthttp.CompressHandler(TimeoutMiddleware(false, AdminMiddleware(handleAdminAvatarReject(adminSrv)))))
+
+	r.GET("/api/v1/health/live", handleHealthLive())
+	r.GET("/api/v1/health/ready", handleHealthReady(dbSrv, taskSrv))
+	// old path used by existing deploys
+	r.GET("/api/v1/healthcheck", handleHealthReady(dbSrv, taskSrv))
 
 	s := &fasthttp.Server{
 		Handler: r.Handler,
 	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.ListenAndServe(config.Config.Listen)
+	}()
 	log.Log.Info("server started")
-	return s.ListenAndServe(config.Config.Listen)
+
+	var errServe error
+	select {
+	case errServe = <-serveErr:
+	case <-ctx.Done():
+		log.Log.Info("server shutting down")
+	}
+	stop()
+
+	ctxShutdown, cancel := context.WithTimeout(context.Background(), config.Config.ShutdownTimeout)
+	defer cancel()
+
+	if errShutdown := shutdownServer(ctxShutdown, s); errors.Is(errShutdown, context.DeadlineExceeded) {
+		log.Log.Warning("shutdown timeout, in-flight requests dropped")
+	} else if errShutdown != nil {
+		log.Log.WithError(errShutdown).Error("server shutdown")
+	}
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(ctxShutdown)
+	}
+
+	workers.Wait()
+	if errWait := mainSrv.Wait(ctxShutdown); errWait != nil {
+		log.Log.WithError(errWait).Warning("shutdown timeout, background jobs dropped")
+	}
+	if errClose := dbSrv.Close(ctxShutdown); errClose != nil {
+		log.Log.WithError(errClose).Error("db close")
+	}
+	log.Log.Info("server stopped")
+	return errServe
 }
 
 func main() {
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"ctfplatform/actions"
+	"ctfplatform/captcha"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/mail"
+	"ctfplatform/models"
+	"ctfplatform/session"
+	"ctfplatform/storage"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+// newTestServer sets authSrv and sessionKeyring to service on migrated in-memory sqlite database
+func newTestServer(t *testing.T) *actions.MainInternal {
+	t.Helper()
+	mainSrv, _ := newTestServerDB(t)
+	return mainSrv
+}
+
+// newTestServerDB returns database too, tests use it to break queries
+func newTestServerDB(t *testing.T) (*actions.MainInternal, *db.DatabaseInternal) {
+	t.Helper()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbSrv.Close(context.Background()) })
+	if _, err := dbSrv.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mainSrv := actions.NewRanking(models.NewTaskDB(dbSrv), models.NewTeamDB(dbSrv), models.NewAuditDB(dbSrv), models.NewAnnouncementDB(dbSrv), mail.LogMailer{}, storage.NewDBStorage(dbSrv), dbSrv)
+
+	keyring, err := session.NewKeyring([]session.Key{session.DeriveKey("test", []byte("aes"), []byte("hmac"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldAuthSrv, oldKeyring := authSrv, sessionKeyring
+	t.Cleanup(func() { authSrv, sessionKeyring = oldAuthSrv, oldKeyring })
+	authSrv, sessionKeyring = mainSrv, keyring
+	return mainSrv, dbSrv
+}
+
+// addTestTeam password is "password"
+func addTestTeam(t *testing.T, s *actions.MainInternal, name string) *models.TeamXXX {
+	t.Helper()
+	ctx := context.Background()
+	team := models.TeamXXX{Name: name, Email: name + "@example.com", Country: "PL"}
+	if err := team.SetPassword("password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTeam(ctx, team); err != nil {
+		t.Fatal(err)
+	}
+	out, err := s.GetTeamByLogin(ctx, team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// testSessionCookie returns cookie value of new session as issued by handleLogin
+func testSessionCookie(t *testing.T, s *actions.MainInternal, team *models.TeamXXX) []byte {
+	t.Helper()
+	sessionID, err := s.NewSession(context.Background(), team.ID, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie, err := sessionKeyring.Marshal(&SessionPermission{TeamID: team.ID, SessionID: sessionID, Version: team.SessionVersion}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cookie
+}
+
+// serveTest runs h with session cookie and authorization header when they are not empty
+func serveTest(h fasthttp.RequestHandler, body string, cookie []byte, authHeader string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(http.MethodPost)
+	ctx.Request.SetBodyString(body)
+	if len(cookie) > 0 {
+		ctx.Request.Header.SetCookieBytesKV([]byte("session"), cookie)
+	}
+	if len(authHeader) > 0 {
+		ctx.Request.Header.Set("Authorization", authHeader)
+	}
+	h(ctx)
+	return ctx
+}
+
+// responseCookie returns value of session cookie set in response
+func responseCookie(ctx *fasthttp.RequestCtx) []byte {
+	cookie := fasthttp.AcquireCookie()
+	defer fasthttp.ReleaseCookie(cookie)
+	cookie.SetKey("session")
+	if !ctx.Response.Header.Cookie(cookie) {
+		return nil
+	}
+	return append([]byte(nil), cookie.Value()...)
+}
+
+func TestPasswordChange(t *testing.T) {
+	s := newTestServer(t)
+	team := addTestTeam(t, s, "change")
+	h := TimeoutMiddleware(true, handlePasswordChange(s))
+
+	current := testSessionCookie(t, s, team)
+	other := testSessionCookie(t, s, team)
+
+	ctx := serveTest(h, `{"current_password":"wrong","new_password":"password2"}`, current, "")
+	if ctx.Response.StatusCode() != http.StatusUnprocessableEntity {
+		t.Fatalf("wrong current password status %d", ctx.Response.StatusCode())
+	}
+	if ctx := serveTest(h, `{}`, other, ""); ctx.Response.StatusCode() == http.StatusUnauthorized {
+		t.Fatal("other session revoked by failed change")
+	}
+
+	ctx = serveTest(h, `{"current_password":"password","new_password":"password2"}`, current, "")
+	if ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("change status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	reissued := responseCookie(ctx)
+	if len(reissued) == 0 {
+		t.Fatal("session cookie not issued again")
+	}
+
+	if ctx := serveTest(h, `{}`, other, ""); ctx.Response.StatusCode() != http.StatusUnauthorized {
+		t.Fatalf("other session status %d, want revoked", ctx.Response.StatusCode())
+	}
+	if ctx := serveTest(h, `{}`, current, ""); ctx.Response.StatusCode() != http.StatusUnauthorized {
+		t.Fatalf("old cookie of current session status %d, want revoked", ctx.Response.StatusCode())
+	}
+	// empty new password is rejected only after authorization, so 400 tells session is valid
+	if ctx := serveTest(h, `{}`, reissued, ""); ctx.Response.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("reissued session status %d", ctx.Response.StatusCode())
+	}
+
+	teamData, err := s.GetTeamByLogin(context.Background(), team.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !teamData.EqualPassword("password2") {
+		t.Fatal("password not changed")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	s := newTestServer(t)
+	team := addTestTeam(t, s, "logout")
+	h := TimeoutMiddleware(true, handleLogout(s))
+
+	current := testSessionCookie(t, s, team)
+	other := testSessionCookie(t, s, team)
+	if ctx := serveTest(h, `{}`, current, ""); ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("logout status %d", ctx.Response.StatusCode())
+	}
+	if ctx := serveTest(h, `{}`, current, ""); ctx.Response.StatusCode() != http.StatusUnauthorized {
+		t.Fatalf("logged out session status %d", ctx.Response.StatusCode())
+	}
+	// session with id is revoked alone
+	if ctx := serveTest(h, `[]`, other, ""); ctx.Response.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("other session status %d", ctx.Response.StatusCode())
+	}
+
+	// old session created before sessions table
+	legacy, err := sessionKeyring.Marshal(&SessionPermission{TeamID: team.ID, Version: team.SessionVersion}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx := serveTest(h, `{}`, legacy, ""); ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("logout old session status %d", ctx.Response.StatusCode())
+	}
+	if ctx := serveTest(h, `{}`, legacy, ""); ctx.Response.StatusCode() != http.StatusUnauthorized {
+		t.Fatalf("logged out old session status %d", ctx.Response.StatusCode())
+	}
+}
+
+func TestSessionKeyringRotatedSecrets(t *testing.T) {
+	saved := *config.Config
+	defer func() { *config.Config = saved }()
+	config.Config.SessionKeys = nil
+	config.Config.AcceptLegacySessions = true
+	config.Config.LegacySessionsUntil = config.DateTimeParser(time.Now().Add(time.Hour))
+	// v1 sessions need aes key of aes size
+	oldAesKey, oldHmacKey := bytes.Repeat([]byte{1}, 32), []byte("old hmac")
+	config.Config.AesSecretKey, config.Config.HmacSecretKey = oldAesKey, oldHmacKey
+
+	oldKeyring, err := newSessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := oldKeyring.Marshal(&SessionPermission{TeamID: 1}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := session.MarshalSession(config.Config.AesSecretKey, config.Config.HmacSecretKey, &SessionPermission{TeamID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Config.AesSecretKey, config.Config.HmacSecretKey = bytes.Repeat([]byte{2}, 32), []byte("new hmac")
+	keyring, err := newSessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out *SessionPermission
+	if _, _, err := keyring.Unmarshal(encoded, &out); err == nil {
+		t.Fatal("session of rotated secrets accepted without previous secrets")
+	}
+
+	config.Config.PreviousAesSecretKey, config.Config.PreviousHmacSecretKey = oldAesKey, oldHmacKey
+	keyring, err = newSessionKeyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, stale, err := keyring.Unmarshal(encoded, &out); err != nil || !stale || out.TeamID != 1 {
+		t.Fatalf("session of previous secrets: stale %v, err %v", stale, err)
+	}
+	if _, _, err := keyring.Unmarshal(legacy, &out); err != nil || out.TeamID != 2 {
+		t.Fatalf("legacy session of previous secrets: %v", err)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	s := newTestServer(t)
+	team := addTestTeam(t, s, "tokens")
+	cookie := testSessionCookie(t, s, team)
+	create := TimeoutMiddleware(true, handleTokenCreate(s))
+	read := TokenMiddleware(models.TokenScopeRead, handleTeamMy(s))
+	submit := TokenMiddleware(models.TokenScopeSubmit, handleFlagSubmit(s))
+	sessionOnly := TimeoutMiddleware(true, handleTokens(s))
+
+	newToken := func(name string, scope string) actions.TeamToken {
+		t.Helper()
+		ctx := serveTest(create, fmt.Sprintf(`{"name":%q,"scope":%q}`, name, scope), cookie, "")
+		if ctx.Response.StatusCode() != http.StatusCreated {
+			t.Fatalf("create token status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+		}
+		var token actions.TeamToken
+		if err := json.Unmarshal(ctx.Response.Body(), &token); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	readToken := newToken(strings.Repeat("ż", 70), models.TokenScopeRead)
+	submitToken := newToken("submit", models.TokenScopeSubmit)
+	if !utf8.ValidString(readToken.Name) || utf8.RuneCountInString(readToken.Name) != 64 {
+		t.Fatalf("token name %q not cut to 64 characters", readToken.Name)
+	}
+	if ctx := serveTest(create, `{"name":"x","scope":"admin"}`, cookie, ""); ctx.Response.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("invalid scope status %d", ctx.Response.StatusCode())
+	}
+
+	for _, tc := range []struct {
+		name   string
+		h      fasthttp.RequestHandler
+		header string
+		status int
+	}{
+		{"read with read token", read, "Bearer " + readToken.Token, http.StatusOK},
+		{"read with submit token", read, "Bearer " + submitToken.Token, http.StatusOK},
+		{"submit with read token", submit, "Bearer " + readToken.Token, http.StatusForbidden},
+		// invalid json is checked after authorization
+		{"submit with submit token", submit, "Bearer " + submitToken.Token, http.StatusBadRequest},
+		{"session endpoint with token", sessionOnly, "Bearer " + submitToken.Token, http.StatusForbidden},
+		{"unknown token", read, "Bearer ctf_unknown", http.StatusUnauthorized},
+		{"not bearer", read, "Basic " + readToken.Token, http.StatusUnauthorized},
+	} {
+		ctx := serveTest(tc.h, `[]`, nil, tc.header)
+		if ctx.Response.StatusCode() != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, ctx.Response.StatusCode(), tc.status)
+		}
+	}
+
+	revoke := TimeoutMiddleware(true, handleTokenRevoke(s))
+	if ctx := serveTest(revoke, fmt.Sprintf(`{"id":%d}`, readToken.ID), cookie, ""); ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("revoke status %d", ctx.Response.StatusCode())
+	}
+	if ctx := serveTest(read, ``, nil, "Bearer "+readToken.Token); ctx.Response.StatusCode() != http.StatusUnauthorized {
+		t.Fatalf("revoked token status %d", ctx.Response.StatusCode())
+	}
+	if ctx := serveTest(read, ``, nil, "Bearer "+submitToken.Token); ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("other token status %d after revoke", ctx.Response.StatusCode())
+	}
+
+	// token of other team can not be revoked
+	otherCookie := testSessionCookie(t, s, addTestTeam(t, s, "other"))
+	if ctx := serveTest(revoke, fmt.Sprintf(`{"id":%d}`, submitToken.ID), otherCookie, ""); ctx.Response.StatusCode() != http.StatusNotFound {
+		t.Fatalf("revoke of other team token status %d", ctx.Response.StatusCode())
+	}
+}
+
+func TestRegisterReservedName(t *testing.T) {
+	s := newTestServer(t)
+	h := TimeoutMiddleware(false, handleRegister(s, captcha.Disabled{}))
+
+	body := `{"name":"deleted-1","email":"squatter@example.com","password":"password","country":"PL"}`
+	ctx := serveTest(h, body, nil, "")
+	if ctx.Response.StatusCode() != http.StatusBadRequest || !strings.Contains(string(ctx.Response.Body()), HttpErrReservedTeamName) {
+		t.Fatalf("register reserved name status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	body = `{"name":"squatter","email":"squatter@example.com","password":"password","country":"PL"}`
+	if ctx := serveTest(h, body, nil, ""); ctx.Response.StatusCode() != http.StatusCreated {
+		t.Fatalf("register status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
+
+func TestInfoCaptcha(t *testing.T) {
+	saved := *config.Config
+	defer func() { *config.Config = saved }()
+	config.Config.CaptchaProvider = captcha.ProviderTurnstile
+	config.Config.CaptchaSiteKey = "site-key"
+
+	s := newTestServer(t)
+	ctx := serveTest(TimeoutMiddleware(false, handleInfo(s)), "", nil, "")
+	var info actions.Info
+	if err := json.Unmarshal(ctx.Response.Body(), &info); err != nil {
+		t.Fatalf("info status %d %q: %v", ctx.Response.StatusCode(), ctx.Response.Body(), err)
+	}
+	if info.CaptchaProvider != captcha.ProviderTurnstile || info.CaptchaSiteKey != "site-key" {
+		t.Fatalf("captcha in info %q %q", info.CaptchaProvider, info.CaptchaSiteKey)
+	}
+}
+
+// TestTeamUpdateAvatarModeration failed avatar submit fails whole update instead of being only logged
+func TestTeamUpdateAvatarModeration(t *testing.T) {
+	saved := *config.Config
+	defer func() { *config.Config = saved }()
+	config.Config.AvatarModeration = true
+
+	s, dbSrv := newTestServerDB(t)
+	ctx := context.Background()
+	team := addTestTeam(t, s, "avatar")
+	cookie := testSessionCookie(t, s, team)
+	h := TimeoutMiddleware(true, handleTeamUpdate(s))
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatal(err)
+	}
+	body := func(affiliation string) string {
+		return fmt.Sprintf(`{"country":"PL","affiliation":%q,"avatar":%q}`, affiliation, base64.StdEncoding.EncodeToString(img.Bytes()))
+	}
+
+	if _, err := dbSrv.Exec(ctx, "CREATE TRIGGER fail_submit BEFORE INSERT ON avatar_moderation BEGIN SELECT RAISE(ABORT, 'fail'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if ctx := serveTest(h, body("failed"), cookie, ""); ctx.Response.StatusCode() != http.StatusInternalServerError {
+		t.Fatalf("failed submit status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	teamData, err := s.GetTeamDB().GetByID(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teamData.Affiliation == "failed" {
+		t.Fatal("team updated with failed avatar submit")
+	}
+
+	if _, err := dbSrv.Exec(ctx, "DROP TRIGGER fail_submit"); err != nil {
+		t.Fatal(err)
+	}
+	if ctx := serveTest(h, body("submitted"), cookie, ""); ctx.Response.StatusCode() != http.StatusOK {
+		t.Fatalf("submit status %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	pending, err := actions.NewAdmin(s).GetAvatarModerations(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].TeamID != team.ID {
+		t.Fatalf("pending avatars %+v", pending)
+	}
+	teamData, err = s.GetTeamDB().GetByID(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teamData.Affiliation != "submitted" || teamData.AvatarPath != team.AvatarPath {
+		t.Fatalf("team affiliation %q avatar %q after submit", teamData.Affiliation, teamData.AvatarPath)
+	}
+}
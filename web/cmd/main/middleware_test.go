@@ -0,0 +1,104 @@
+package main
+
+import (
+	"ctfplatform/config"
+	"ctfplatform/metrics"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// spanRecorder is set once, global tracer delegates only to the first provider
+var spanRecorder = sync.OnceValue(func() *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	return recorder
+})
+
+// requestCount returns value of http requests counter of route with given status
+func requestCount(route string, status int) float64 {
+	return testutil.ToFloat64(metrics.HttpRequests.WithLabelValues(route, http.MethodPost, strconv.Itoa(status)))
+}
+
+// TestTimeoutMiddlewareStatus metrics and span get status decided by middleware, not response which
+// handler goroutine can still write after timeout
+func TestTimeoutMiddlewareStatus(t *testing.T) {
+	saved := *config.Config
+	defer func() { *config.Config = saved }()
+	config.Config.RequestTimeout = 50 * time.Millisecond
+
+	recorder := spanRecorder()
+	spansBefore := len(recorder.Ended())
+
+	release := make(chan struct{})
+	written := make(chan struct{})
+	defer func() {
+		close(release)
+		<-written
+	}()
+	handlers := map[string]fasthttp.RequestHandler{
+		"/test/ok": func(ctx *fasthttp.RequestCtx) {
+			ctx.SetStatusCode(http.StatusCreated)
+		},
+		"/test/timeout": func(ctx *fasthttp.RequestCtx) {
+			defer close(written)
+			<-release
+			ctx.SetStatusCode(http.StatusOK)
+		},
+		"/test/panic": func(ctx *fasthttp.RequestCtx) {
+			panic("handler panic")
+		},
+	}
+	want := map[string]int{
+		"/test/ok":      http.StatusCreated,
+		"/test/timeout": http.StatusServiceUnavailable,
+		"/test/panic":   http.StatusInternalServerError,
+	}
+
+	for route, h := range handlers {
+		before := requestCount(route, want[route])
+		func() {
+			defer func() {
+				if err := recover(); (err != nil) != (route == "/test/panic") {
+					t.Fatalf("%s recovered %v", route, err)
+				}
+			}()
+			ctx := &fasthttp.RequestCtx{}
+			ctx.Request.Header.SetMethod(http.MethodPost)
+			ctx.SetUserValue("_route", route)
+			TimeoutMiddleware(false, h)(ctx)
+		}()
+		if got := requestCount(route, want[route]) - before; got != 1 {
+			t.Fatalf("%s counted %v times with status %d", route, got, want[route])
+		}
+	}
+
+	spans := recorder.Ended()[spansBefore:]
+	if len(spans) != len(handlers) {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	for _, span := range spans {
+		var route string
+		var status int64
+		for _, attr := range span.Attributes() {
+			switch attr.Key {
+			case semconv.HTTPRouteKey:
+				route = attr.Value.AsString()
+			case semconv.HTTPResponseStatusCodeKey:
+				status = attr.Value.AsInt64()
+			}
+		}
+		if status != int64(want[route]) {
+			t.Fatalf("span of %s status %d, want %d", route, status, want[route])
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// startTestServer serves h on random local port, returns its url
+func startTestServer(t *testing.T, h fasthttp.RequestHandler) (*fasthttp.Server, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fasthttp.Server{Handler: h}
+	go s.Serve(ln)
+	return s, "http://" + ln.Addr().String()
+}
+
+func TestShutdownServerWaitsForRequest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s, url := startTestServer(t, func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		<-release
+		ctx.SetStatusCode(http.StatusCreated)
+	})
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Error(err)
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownDone := make(chan error, 1)
+	go func() {
+		shutdownDone <- shutdownServer(ctx, s)
+	}()
+	select {
+	case err := <-shutdownDone:
+		t.Fatalf("shutdown returned with request in flight: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if code := <-status; code != http.StatusCreated {
+		t.Fatalf("in-flight request status %d", code)
+	}
+	if err := <-shutdownDone; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.Get(url); err == nil {
+		t.Fatal("request accepted after shutdown")
+	}
+}
+
+func TestShutdownServerTimeout(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	s, url := startTestServer(t, func(ctx *fasthttp.RequestCtx) {
+		close(started)
+		<-release
+	})
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := shutdownServer(ctx, s); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("shutdown with stuck request: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckTimes(t *testing.T) {
+	saved := *Config
+	defer func() { *Config = saved }()
+
+	at := func(hour int) DateTimeParser {
+		return DateTimeParser(time.Date(2030, 1, 1, hour, 0, 0, 0, time.UTC))
+	}
+	tests := []struct {
+		name                               string
+		start, end, freezeStart, freezeEnd DateTimeParser
+		wantErr                            bool
+	}{
+		{"freeze inside", at(1), at(10), at(8), at(10), false},
+		{"no freeze", at(1), at(10), at(10), at(10), false},
+		{"start after end", at(10), at(1), at(5), at(5), true},
+		{"start equal end", at(1), at(1), at(1), at(1), true},
+		{"freeze reversed", at(1), at(10), at(9), at(8), true},
+		{"freeze before start", at(2), at(10), at(1), at(5), true},
+		{"freeze after end", at(1), at(10), at(8), at(11), true},
+	}
+	for _, tt := range tests {
+		Config.StartCompetition, Config.EndCompetition = tt.start, tt.end
+		Config.FreezeStartCompetition, Config.FreezeEndCompetition = tt.freezeStart, tt.freezeEnd
+		if err := CheckTimes(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: err %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
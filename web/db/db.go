@@ -2,63 +2,197 @@ package db
 
 import (
 	"context"
+	"ctfplatform/metrics"
+	"ctfplatform/tracing"
 	"database/sql"
 	"errors"
-	"github.com/go-sql-driver/mysql"
-	_ "github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
 var ErrAlreadyExistsDB = errors.New("already exists in db")
+var ErrReferencedDB = errors.New("row is referenced in db")
 
 type DatabaseInternal struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect Dialect
 }
 
-func NewDB(mysqlDSN string) (*DatabaseInternal, error) {
-	db, err := sql.Open("mysql", mysqlDSN)
+// NewDB dsn is mysql dsn, "postgres://..." url or "sqlite://<path>" ("sqlite://:memory:" for in-memory database)
+func NewDB(dsn string) (*DatabaseInternal, error) {
+	dialect, dsn := dialectFromDSN(dsn)
+	db, err := dialect.open(dsn)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DatabaseInternal{
-		db: db,
+		db:      db,
+		dialect: dialect,
 	}, nil
 }
 
+func (s *DatabaseInternal) Dialect() Dialect {
+	return s.dialect
+}
+
 func (s *DatabaseInternal) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
 }
 
+// Stats returns connection pool stats
+func (s *DatabaseInternal) Stats() sql.DBStats {
+	return s.db.Stats()
+}
+
+var funcNameReplacer = strings.NewReplacer("(*", "", ")", "")
+
+// queryName is name of model method which runs query, e.g. "models.TeamInternal.GetByID"
+func queryName() string {
+	// queryName <- start <- Exec/Query/... <- model method
+	pc, _, _, ok := runtime.Caller(3)
+	if !ok {
+		return "query"
+	}
+	name := runtime.FuncForPC(pc).Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	return funcNameReplacer.Replace(name)
+}
+
+// start opens span for query, returned func ends it and records latency
+func (s *DatabaseInternal) start(ctx context.Context, op string, query string) (context.Context, func(err error)) {
+	now := time.Now()
+	ctx, span := tracing.Tracer.Start(ctx, queryName(),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemKey.String(s.dialect.Name()),
+			semconv.DBOperation(op),
+			semconv.DBStatement(query),
+		),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+		metrics.ObserveDB(op, now)
+	}
+}
+
+// querier is *sql.DB, *sql.Conn or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+// conn returns transaction started by Tx for this ctx
+func (s *DatabaseInternal) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return s.db
+}
+
+// Tx runs f in transaction, queries made with ctx passed to f are part of it.
+// Nested Tx joins outer transaction, f error (or panic) rolls everything back.
+func (s *DatabaseInternal) Tx(ctx context.Context, f func(ctx context.Context) error) (err error) {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return f(ctx)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return s.dialect.wrapErr(err)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := f(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return s.dialect.wrapErr(tx.Commit())
+}
+
 func (s *DatabaseInternal) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	result, err := s.db.ExecContext(ctx, query, args...)
-	return result, wrapErr(err)
+	ctx, end := s.start(ctx, "exec", query)
+	result, err := s.conn(ctx).ExecContext(ctx, s.dialect.rebind(query), s.dialect.args(args)...)
+	err = s.dialect.wrapErr(err)
+	end(err)
+	return result, err
 }
 
+// Insert returns id of new row, query should not set id column
+func (s *DatabaseInternal) Insert(ctx context.Context, query string, args ...interface{}) (int, error) {
+	ctx, end := s.start(ctx, "insert", query)
+	id, err := s.dialect.insert(ctx, s.conn(ctx), s.dialect.rebind(query), s.dialect.args(args))
+	err = s.dialect.wrapErr(err)
+	end(err)
+	return int(id), err
+}
+
+// QueryRow span ends in Row.Scan
 func (s *DatabaseInternal) QueryRow(ctx context.Context, query string, args ...interface{}) *Row {
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	return &Row{Err: wrapErr(err), rows: rows}
+	ctx, end := s.start(ctx, "query_row", query)
+	rows, err := s.conn(ctx).QueryContext(ctx, s.dialect.rebind(query), s.dialect.args(args)...)
+	if err != nil {
+		err = s.dialect.wrapErr(err)
+		end(err)
+		return &Row{Err: err}
+	}
+	return &Row{rows: rows, end: end}
 }
 
-func (s *DatabaseInternal) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	rows, err := s.db.QueryContext(ctx, query, args...)
-	return rows, wrapErr(err)
+// Query span ends in Rows.Close, so it covers reading of rows too
+func (s *DatabaseInternal) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	ctx, end := s.start(ctx, "query", query)
+	rows, err := s.conn(ctx).QueryContext(ctx, s.dialect.rebind(query), s.dialect.args(args)...)
+	if err != nil {
+		err = s.dialect.wrapErr(err)
+		end(err)
+		return nil, err
+	}
+	return &Rows{Rows: rows, end: end}, nil
 }
 
 func (s *DatabaseInternal) Close(ctx context.Context) error {
-	if s.db != nil {
+	if s.db == nil {
 		return nil
 	}
 	return s.db.Close()
 }
 
-func wrapErr(err error) error {
-	me, ok := err.(*mysql.MySQLError)
-	if !ok {
-		return err
-	}
-	if me.Number == 1062 {
-		return ErrAlreadyExistsDB
-	}
+// Rows should be closed also after reading all rows, Close ends query span
+type Rows struct {
+	*sql.Rows
+	end  func(err error)
+	once sync.Once
+}
+
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	r.once.Do(func() {
+		if rowsErr := r.Rows.Err(); rowsErr != nil {
+			r.end(rowsErr)
+		} else {
+			r.end(err)
+		}
+	})
 	return err
 }
 
@@ -66,12 +200,20 @@ func wrapErr(err error) error {
 type Row struct {
 	Err  error
 	rows *sql.Rows
+	end  func(err error)
 }
 
-func (r *Row) Scan(dest ...interface{}) error {
+func (r *Row) Scan(dest ...interface{}) (err error) {
 	if r.Err != nil {
 		return r.Err
 	}
+	defer func() {
+		if err == sql.ErrNoRows {
+			r.end(nil)
+		} else {
+			r.end(err)
+		}
+	}()
 	defer r.rows.Close()
 	for _, dp := range dest {
 		if _, ok := dp.(*sql.RawBytes); ok {
@@ -85,8 +227,7 @@ func (r *Row) Scan(dest ...interface{}) error {
 		}
 		return sql.ErrNoRows
 	}
-	err := r.rows.Scan(dest...)
-	if err != nil {
+	if err := r.rows.Scan(dest...); err != nil {
 		return err
 	}
 	return r.rows.Close()
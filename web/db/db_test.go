@@ -0,0 +1,58 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+func TestQuerySpanEndsOnClose(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	ctx := context.Background()
+	dbSrv := newTestDB(t, "sqlite://:memory:")
+	ended := func() int {
+		return len(recorder.Ended())
+	}
+
+	before := ended()
+	rows, err := dbSrv.Query(ctx, "SELECT version FROM schema_version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ended() != before {
+		t.Fatal("query span ended before rows were closed")
+	}
+	rows.Close()
+	rows.Close()
+	if ended() != before+1 {
+		t.Fatalf("%d spans ended after close, want 1", ended()-before)
+	}
+
+	row := dbSrv.QueryRow(ctx, "SELECT version FROM schema_version WHERE version = ?", -1)
+	if ended() != before+1 {
+		t.Fatal("query row span ended before scan")
+	}
+	var version int
+	if err := row.Scan(&version); err != sql.ErrNoRows {
+		t.Fatalf("scan missing row: %v", err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != before+2 {
+		t.Fatalf("%d spans ended after scan, want 1", len(spans)-before-1)
+	}
+	if status := spans[len(spans)-1].Status(); status.Description != "" {
+		t.Fatalf("no rows recorded as error: %+v", status)
+	}
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+const (
+	DialectMySQL    = "mysql"
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+// Dialect hides differences between databases, models should write portable sql
+// (NOW(), GREATEST, LOG2 are provided by every dialect) and use Insert for new rows.
+type Dialect interface {
+	Name() string
+	// OnConflictUpdate returns clause appended to INSERT, which updates given columns when conflict columns are duplicated
+	OnConflictUpdate(conflict []string, update []string) string
+
+	open(dsn string) (*sql.DB, error)
+	rebind(query string) string
+	args(args []interface{}) []interface{}
+	insert(ctx context.Context, db querier, query string, args []interface{}) (int64, error)
+	wrapErr(err error) error
+	isMissingTable(err error) bool
+	columnExists(ctx context.Context, conn *sql.Conn, table string, column string) (bool, error)
+	indexExists(ctx context.Context, conn *sql.Conn, table string, index string) (bool, error)
+	lock(ctx context.Context, conn *sql.Conn) error
+	unlock(ctx context.Context, conn *sql.Conn) error
+	migrations() []Migration
+}
+
+// dialectFromDSN picks dialect by dsn scheme, dsn without scheme is mysql one (user:pass@tcp(host)/db)
+func dialectFromDSN(dsn string) (Dialect, string) {
+	// postgres driver understands url itself
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresDialect{}, dsn
+	}
+	for _, prefix := range []string{"sqlite://", "sqlite:"} {
+		if strings.HasPrefix(dsn, prefix) {
+			return sqliteDialect{}, strings.TrimPrefix(dsn, prefix)
+		}
+	}
+	return mysqlDialect{}, strings.TrimPrefix(dsn, "mysql://")
+}
@@ -0,0 +1,181 @@
+package db_test
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"ctfplatform/models"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testDSNs sqlite runs always, postgres only with TEST_POSTGRES_DSN (every run uses its own schema)
+func testDSNs(t *testing.T) map[string]string {
+	out := map[string]string{
+		db.DialectSQLite: "sqlite://:memory:",
+	}
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); len(dsn) > 0 {
+		out[db.DialectPostgres] = postgresSchema(t, dsn)
+	}
+	return out
+}
+
+func postgresSchema(t *testing.T, dsn string) string {
+	t.Helper()
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := conn.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := conn.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "search_path=" + schema
+}
+
+func TestMigrate(t *testing.T) {
+	for name, dsn := range testDSNs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dbSrv := newTestDB(t, dsn)
+			if dbSrv.Dialect().Name() != name {
+				t.Fatalf("dialect %s, want %s", dbSrv.Dialect().Name(), name)
+			}
+			if err := dbSrv.CheckSchema(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			// second run has nothing to do
+			applied, err := dbSrv.Migrate(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(applied) != 0 {
+				t.Fatalf("migrated again %d migrations", len(applied))
+			}
+			status, err := dbSrv.MigrationStatus(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(status) != dbSrv.LatestVersion() {
+				t.Fatalf("got %d migrations, want %d", len(status), dbSrv.LatestVersion())
+			}
+			for _, migration := range status {
+				if migration.AppliedAt == nil {
+					t.Fatalf("migration %d %s not applied", migration.Version, migration.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	for name, dsn := range testDSNs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			teamDB := models.NewTeamDB(newTestDB(t, dsn))
+
+			before := time.Now().Add(-time.Minute)
+			var ids []int
+			for _, teamName := range []string{"first", "second"} {
+				id, err := teamDB.AddTeam(ctx, models.TeamXXX{Name: teamName, Email: teamName + "@example.com", Country: "PL"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+			if ids[0] <= 0 || ids[1] <= ids[0] {
+				t.Fatalf("ids %v", ids)
+			}
+
+			team, err := teamDB.GetByID(ctx, ids[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if team.Name != "second" {
+				t.Fatalf("id %d is team %s", ids[1], team.Name)
+			}
+			// NOW() in insert
+			if team.CreatedAt.Before(before) || team.CreatedAt.After(time.Now().Add(time.Minute)) {
+				t.Fatalf("created at %s, now %s", team.CreatedAt, time.Now())
+			}
+
+			if _, err := teamDB.AddTeam(ctx, models.TeamXXX{Name: "first", Email: "other@example.com", Country: "PL"}); err != db.ErrAlreadyExistsDB {
+				t.Fatalf("duplicated team: %v", err)
+			}
+		})
+	}
+}
+
+func TestScoreboard(t *testing.T) {
+	defer func(start, end config.DateTimeParser) {
+		config.Config.StartCompetition, config.Config.EndCompetition = start, end
+	}(config.Config.StartCompetition, config.Config.EndCompetition)
+	config.Config.StartCompetition = config.DateTimeParser(time.Now().Add(-time.Hour))
+	config.Config.EndCompetition = config.DateTimeParser(time.Now().Add(time.Hour))
+
+	for name, dsn := range testDSNs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dbSrv := newTestDB(t, dsn)
+			teamDB, taskDB, auditDB := models.NewTeamDB(dbSrv), models.NewTaskDB(dbSrv), models.NewAuditDB(dbSrv)
+
+			teams := make([]int, 2)
+			for i := range teams {
+				id, err := teamDB.AddTeam(ctx, models.TeamXXX{Name: fmt.Sprintf("team%d", i), Email: fmt.Sprintf("team%d@example.com", i), Active: true, Country: "PL"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				teams[i] = id
+			}
+			tasks := make([]int, 2)
+			for i := range tasks {
+				id, err := taskDB.AddTask(ctx, models.TaskXXX{Name: fmt.Sprintf("task%d", i), Category: "web", Difficult: "easy"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				tasks[i] = id
+			}
+			// task0 solved by both teams, task1 only by team0
+			for _, solve := range [][2]int{{teams[0], tasks[0]}, {teams[1], tasks[0]}, {teams[0], tasks[1]}} {
+				if err := auditDB.AddSolve(ctx, solve[0], solve[1]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			scoreboard, err := auditDB.GetScoreboard(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(scoreboard) != 2 {
+				t.Fatalf("got %d teams on scoreboard, want 2", len(scoreboard))
+			}
+			// 500 - 80 * log2((solves + 3) / 4), two solves give 474
+			want := []struct{ team, points, solved int }{
+				{teams[0], 974, 2},
+				{teams[1], 474, 1},
+			}
+			for i, row := range scoreboard {
+				if row.TeamID != want[i].team || row.Points != want[i].points || row.TaskSolved != want[i].solved {
+					t.Errorf("place %d: team %d, %d points, %d solved, want %+v", i+1, row.TeamID, row.Points, row.TaskSolved, want[i])
+				}
+			}
+		})
+	}
+}
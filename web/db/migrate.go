@@ -0,0 +1,201 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrSchemaOutdated = errors.New("db schema outdated")
+
+// Step should be idempotent, mysql can not rollback DDL so failed migration is just run again
+type Step func(ctx context.Context, conn *sql.Conn, dialect Dialect) error
+
+type Migration struct {
+	Version int
+	Name    string
+	Steps   []Step
+}
+
+// SQL runs single statement
+func SQL(query string) Step {
+	return func(ctx context.Context, conn *sql.Conn, dialect Dialect) error {
+		_, err := conn.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// AddColumn adds column only when it does not exist yet, mysql has no ADD COLUMN IF NOT EXISTS
+func AddColumn(table string, column string, definition string) Step {
+	return func(ctx context.Context, conn *sql.Conn, dialect Dialect) error {
+		exists, err := dialect.columnExists(ctx, conn, table, column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+		return err
+	}
+}
+
+// CreateIndex creates index only when it does not exist yet, mysql has no CREATE INDEX IF NOT EXISTS
+func CreateIndex(table string, index string, unique bool, columns ...string) Step {
+	return func(ctx context.Context, conn *sql.Conn, dialect Dialect) error {
+		exists, err := dialect.indexExists(ctx, conn, table, index)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return nil
+		}
+		kind := "INDEX"
+		if unique {
+			kind = "UNIQUE INDEX"
+		}
+		_, err = conn.ExecContext(ctx, fmt.Sprintf("CREATE %s %s ON %s (%s)", kind, index, table, strings.Join(columns, ", ")))
+		return err
+	}
+}
+
+// IfIndexExists runs step only when index exists, e.g. mysql DROP INDEX which has no IF EXISTS
+func IfIndexExists(table string, index string, step Step) Step {
+	return func(ctx context.Context, conn *sql.Conn, dialect Dialect) error {
+		exists, err := dialect.indexExists(ctx, conn, table, index)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+		return step(ctx, conn, dialect)
+	}
+}
+
+func (s *DatabaseInternal) LatestVersion() int {
+	migrations := s.dialect.migrations()
+	return migrations[len(migrations)-1].Version
+}
+
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+func appliedMigrations(ctx context.Context, dialect Dialect, q querier) (map[int]time.Time, error) {
+	query := `
+SELECT version, applied_at FROM schema_version
+`
+	rows, err := q.QueryContext(ctx, query)
+	if dialect.isMissingTable(err) {
+		// table does not exist, nothing was migrated
+		return map[int]time.Time{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		out[version] = appliedAt
+	}
+	return out, rows.Err()
+}
+
+func (s *DatabaseInternal) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(ctx, s.dialect, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := s.dialect.migrations()
+	out := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		out[i] = MigrationStatus{
+			Version: m.Version,
+			Name:    m.Name,
+		}
+		if appliedAt, exists := applied[m.Version]; exists {
+			out[i].AppliedAt = &appliedAt
+		}
+	}
+	return out, nil
+}
+
+// CheckSchema returns ErrSchemaOutdated when any known migration is not applied
+func (s *DatabaseInternal) CheckSchema(ctx context.Context) error {
+	applied, err := appliedMigrations(ctx, s.dialect, s.db)
+	if err != nil {
+		return err
+	}
+	for _, m := range s.dialect.migrations() {
+		if _, exists := applied[m.Version]; !exists {
+			return fmt.Errorf("%w: migration %d (%s) not applied", ErrSchemaOutdated, m.Version, m.Name)
+		}
+	}
+	return nil
+}
+
+// Migrate applies missing migrations in order and returns them
+func (s *DatabaseInternal) Migrate(ctx context.Context) ([]Migration, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// lock belongs to connection, so everything below uses the same conn
+	if err := s.dialect.lock(ctx, conn); err != nil {
+		return nil, fmt.Errorf("get migrate lock: %w", err)
+	}
+	defer s.dialect.unlock(context.Background(), conn)
+
+	query := `
+CREATE TABLE IF NOT EXISTS schema_version
+(
+	version int not null,
+	name varchar(255) not null,
+	applied_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint schema_version_pk
+		primary key (version)
+)
+`
+	if _, err := conn.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("create schema_version: %w", err)
+	}
+
+	applied, err := appliedMigrations(ctx, s.dialect, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Migration
+	for _, m := range s.dialect.migrations() {
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+		for i, step := range m.Steps {
+			if err := step(ctx, conn, s.dialect); err != nil {
+				return out, fmt.Errorf("migration %d (%s) step %d: %w", m.Version, m.Name, i+1, err)
+			}
+		}
+		query := `
+INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, NOW())
+`
+		if _, err := conn.ExecContext(ctx, s.dialect.rebind(query), m.Version, m.Name); err != nil {
+			return out, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
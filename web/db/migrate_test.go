@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+// TestMigrationsInSync every dialect has the same migrations, numbered from 1 without gaps
+func TestMigrationsInSync(t *testing.T) {
+	for name, migrations := range map[string][]Migration{
+		DialectPostgres: postgresMigrations,
+		DialectSQLite:   sqliteMigrations,
+	} {
+		if len(migrations) != len(mysqlMigrations) {
+			t.Errorf("%s has %d migrations, mysql %d", name, len(migrations), len(mysqlMigrations))
+			continue
+		}
+		for i, m := range migrations {
+			if m.Version != i+1 || mysqlMigrations[i].Version != i+1 {
+				t.Errorf("%s migration %d has version %d, mysql %d", name, i+1, m.Version, mysqlMigrations[i].Version)
+			}
+			if m.Name != mysqlMigrations[i].Name {
+				t.Errorf("%s migration %d is %q, mysql %q", name, i+1, m.Name, mysqlMigrations[i].Name)
+			}
+		}
+	}
+}
+
+// TestTeamAvatarMigration avatars kept one per team survive move to row per avatar path
+func TestTeamAvatarMigration(t *testing.T) {
+	ctx := context.Background()
+	dbSrv, err := NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbSrv.Close(ctx)
+	conn, err := dbSrv.db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	const version = 11
+	var perPath Migration
+	run := func(m Migration) {
+		for i, step := range m.Steps {
+			if err := step(ctx, conn, dbSrv.dialect); err != nil {
+				t.Fatalf("migration %d step %d: %v", m.Version, i+1, err)
+			}
+		}
+	}
+	for _, m := range dbSrv.dialect.migrations() {
+		if m.Version == version {
+			perPath = m
+			break
+		}
+		run(m)
+	}
+
+	if _, err := conn.ExecContext(ctx, `INSERT INTO team (id, name, email, password, avatar, country) VALUES (1, 'team', 'team@example.com', '', 'old.png', 'PL')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO team_avatar (team_id, avatar_path, avatar) VALUES (1, 'old.png', 'old')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO team_avatar (team_id, avatar_path, avatar) VALUES (1, 'new.png', 'new')`); err == nil {
+		t.Fatal("second avatar of team stored before migration")
+	}
+
+	// interrupted migration is run again
+	run(perPath)
+	run(perPath)
+
+	var avatar string
+	if err := conn.QueryRowContext(ctx, `SELECT avatar FROM team_avatar WHERE avatar_path = 'old.png'`).Scan(&avatar); err != nil || avatar != "old" {
+		t.Fatalf("old avatar %q after migration: %v", avatar, err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO team_avatar (team_id, avatar_path, avatar) VALUES (1, 'new.png', 'new')`); err != nil {
+		t.Fatalf("second avatar of team: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO team_avatar (team_id, avatar_path, avatar) VALUES (1, 'new.png', 'other')`); err == nil {
+		t.Fatal("duplicated avatar path stored")
+	}
+	for _, index := range []string{"team_avatar_team_id_index", "team_avatar_avatar_path_uindex"} {
+		if exists, err := dbSrv.dialect.indexExists(ctx, conn, "team_avatar", index); err != nil || !exists {
+			t.Fatalf("index %s exists %v: %v", index, exists, err)
+		}
+	}
+}
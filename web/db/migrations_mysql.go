@@ -0,0 +1,243 @@
+package db
+
+// mysqlMigrations are applied in order, never edit applied migration - add new one instead.
+// Every step should be safe to run again (IF NOT EXISTS, AddColumn), mysql commits DDL immediately.
+var mysqlMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS announcement
+(
+	id int auto_increment,
+	title varchar(255) not null,
+	description text not null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint announcement_pk
+		primary key (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS task
+(
+	id int auto_increment,
+	name varchar(255) not null,
+	description text not null,
+	category varchar(128) not null,
+	difficult varchar(32) not null,
+	started_at timestamp default null null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint task_pk
+		primary key (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS task_flags
+(
+	id int auto_increment,
+	task_id int not null,
+	flag varchar(255) not null unique,
+	constraint task_flags_pk
+		primary key (id),
+	constraint task_flags_task_id_fk
+		foreign key (task_id) references task (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team
+(
+	id int auto_increment,
+	name varchar(255) not null unique,
+	email varchar(255) not null unique,
+	password varchar(255) not null,
+	active boolean default false not null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	avatar varchar(64) not null,
+	country varchar(4) not null,
+	constraint team_pk
+		primary key (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_avatar
+(
+	id int auto_increment,
+	team_id int not null unique,
+	avatar_path varchar(64) not null,
+	avatar MEDIUMBLOB not null,
+	constraint team_avatar_pk
+		primary key (id),
+	constraint team_avatar_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS audit
+(
+	id int auto_increment,
+	team_id int not null,
+	task_id int not null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint audit_pk
+		primary key (id),
+	constraint audit_team_id_fk
+		foreign key (team_id) references team (id),
+	constraint audit_task_id_fk
+		foreign key (task_id) references task (id),
+	constraint audit_task_id_team_id_uindex
+		unique (task_id, team_id)
+)`),
+		},
+	},
+	{
+		Version: 2,
+		Name:    "team affiliation and website",
+		Steps: []Step{
+			AddColumn("team", "affiliation", "varchar(64) not null default ''"),
+			AddColumn("team", "website", "varchar(255) not null default ''"),
+		},
+	},
+	{
+		Version: 3,
+		Name:    "password reset",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS password_reset
+(
+	id int auto_increment,
+	team_id int not null,
+	token_hash varchar(64) not null unique,
+	expires_at timestamp not null,
+	used_at timestamp default null null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint password_reset_pk
+		primary key (id),
+	constraint password_reset_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 4,
+		Name:    "team sessions",
+		Steps: []Step{
+			AddColumn("team", "session_version", "int not null default 0"),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_session
+(
+	id int auto_increment,
+	team_id int not null,
+	user_ip varchar(64) not null default '',
+	user_agent varchar(255) not null default '',
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	last_seen_at timestamp default CURRENT_TIMESTAMP not null,
+	revoked_at timestamp default null null,
+	constraint team_session_pk
+		primary key (id),
+	constraint team_session_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 5,
+		Name:    "team api tokens",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_token
+(
+	id int auto_increment,
+	team_id int not null,
+	name varchar(64) not null default '',
+	scope varchar(16) not null,
+	token_hash varchar(64) not null unique,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	last_used_at timestamp default null null,
+	revoked_at timestamp default null null,
+	constraint team_token_pk
+		primary key (id),
+	constraint team_token_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 6,
+		Name:    "task slug",
+		Steps: []Step{
+			AddColumn("task", "slug", "varchar(64) default null null unique after id"),
+		},
+	},
+	{
+		Version: 7,
+		Name:    "team moderation",
+		Steps: []Step{
+			AddColumn("team", "state", "varchar(16) not null default 'active' after active"),
+			AddColumn("team", "state_reason", "varchar(255) not null default '' after state"),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_moderation
+(
+	id int auto_increment,
+	team_id int not null,
+	admin varchar(64) not null,
+	state varchar(16) not null,
+	reason varchar(255) not null default '',
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint team_moderation_pk
+		primary key (id),
+	constraint team_moderation_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 8,
+		Name:    "team deletion",
+		Steps: []Step{
+			AddColumn("team", "deleted_at", "timestamp null default null"),
+		},
+	},
+	{
+		Version: 9,
+		Name:    "cache version",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS cache_version
+(
+	name varchar(64) not null,
+	version bigint default 0 not null,
+	constraint cache_version_pk
+		primary key (name)
+)`),
+			SQL(`INSERT IGNORE INTO cache_version (name, version) VALUES ('flags', 0)`),
+		},
+	},
+	{
+		Version: 10,
+		Name:    "avatar moderation",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS avatar_moderation
+(
+	id int auto_increment,
+	team_id int not null,
+	avatar MEDIUMBLOB not null,
+	state varchar(16) not null,
+	reason varchar(255) not null default '',
+	admin varchar(64) not null default '',
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	reviewed_at timestamp null default null,
+	constraint avatar_moderation_pk
+		primary key (id),
+	constraint avatar_moderation_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 11,
+		Name:    "team avatar per path",
+		Steps: []Step{
+			// new avatar is stored next to old one until team points to it, foreign key needs team_id index first
+			CreateIndex("team_avatar", "team_avatar_team_id_index", false, "team_id"),
+			IfIndexExists("team_avatar", "team_id", SQL(`ALTER TABLE team_avatar DROP INDEX team_id`)),
+			CreateIndex("team_avatar", "team_avatar_avatar_path_uindex", true, "avatar_path"),
+		},
+	},
+}
@@ -0,0 +1,269 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// sqliteMigrations mirror mysqlMigrations version by version, keep them in sync.
+// Sqlite can not add unique column or constraint later, so unique indexes are created separately.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS announcement
+(
+	id integer primary key autoincrement,
+	title varchar(255) not null,
+	description text not null,
+	created_at timestamp default CURRENT_TIMESTAMP not null
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS task
+(
+	id integer primary key autoincrement,
+	name varchar(255) not null,
+	description text not null,
+	category varchar(128) not null,
+	difficult varchar(32) not null,
+	started_at timestamp default null,
+	created_at timestamp default CURRENT_TIMESTAMP not null
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS task_flags
+(
+	id integer primary key autoincrement,
+	task_id int not null,
+	flag varchar(255) not null unique,
+	constraint task_flags_task_id_fk
+		foreign key (task_id) references task (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team
+(
+	id integer primary key autoincrement,
+	name varchar(255) not null unique,
+	email varchar(255) not null unique,
+	password varchar(255) not null,
+	active boolean default false not null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	avatar varchar(64) not null,
+	country varchar(4) not null
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_avatar
+(
+	id integer primary key autoincrement,
+	team_id int not null unique,
+	avatar_path varchar(64) not null,
+	avatar blob not null,
+	constraint team_avatar_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+			SQL(`
+CREATE TABLE IF NOT EXISTS audit
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	task_id int not null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint audit_team_id_fk
+		foreign key (team_id) references team (id),
+	constraint audit_task_id_fk
+		foreign key (task_id) references task (id),
+	constraint audit_task_id_team_id_uindex
+		unique (task_id, team_id)
+)`),
+		},
+	},
+	{
+		Version: 2,
+		Name:    "team affiliation and website",
+		Steps: []Step{
+			AddColumn("team", "affiliation", "varchar(64) not null default ''"),
+			AddColumn("team", "website", "varchar(255) not null default ''"),
+		},
+	},
+	{
+		Version: 3,
+		Name:    "password reset",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS password_reset
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	token_hash varchar(64) not null unique,
+	expires_at timestamp not null,
+	used_at timestamp default null,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint password_reset_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 4,
+		Name:    "team sessions",
+		Steps: []Step{
+			AddColumn("team", "session_version", "int not null default 0"),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_session
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	user_ip varchar(64) not null default '',
+	user_agent varchar(255) not null default '',
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	last_seen_at timestamp default CURRENT_TIMESTAMP not null,
+	revoked_at timestamp default null,
+	constraint team_session_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 5,
+		Name:    "team api tokens",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_token
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	name varchar(64) not null default '',
+	scope varchar(16) not null,
+	token_hash varchar(64) not null unique,
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	last_used_at timestamp default null,
+	revoked_at timestamp default null,
+	constraint team_token_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 6,
+		Name:    "task slug",
+		Steps: []Step{
+			AddColumn("task", "slug", "varchar(64) default null"),
+			SQL(`CREATE UNIQUE INDEX IF NOT EXISTS task_slug_uindex ON task (slug)`),
+		},
+	},
+	{
+		Version: 7,
+		Name:    "team moderation",
+		Steps: []Step{
+			AddColumn("team", "state", "varchar(16) not null default 'active'"),
+			AddColumn("team", "state_reason", "varchar(255) not null default ''"),
+			SQL(`
+CREATE TABLE IF NOT EXISTS team_moderation
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	admin varchar(64) not null,
+	state varchar(16) not null,
+	reason varchar(255) not null default '',
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	constraint team_moderation_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 8,
+		Name:    "team deletion",
+		Steps: []Step{
+			AddColumn("team", "deleted_at", "timestamp default null"),
+		},
+	},
+	{
+		Version: 9,
+		Name:    "cache version",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS cache_version
+(
+	name varchar(64) not null,
+	version bigint default 0 not null,
+	constraint cache_version_pk
+		primary key (name)
+)`),
+			SQL(`INSERT OR IGNORE INTO cache_version (name, version) VALUES ('flags', 0)`),
+		},
+	},
+	{
+		Version: 10,
+		Name:    "avatar moderation",
+		Steps: []Step{
+			SQL(`
+CREATE TABLE IF NOT EXISTS avatar_moderation
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	avatar blob not null,
+	state varchar(16) not null,
+	reason varchar(255) not null default '',
+	admin varchar(64) not null default '',
+	created_at timestamp default CURRENT_TIMESTAMP not null,
+	reviewed_at timestamp default null,
+	constraint avatar_moderation_team_id_fk
+		foreign key (team_id) references team (id)
+)`),
+		},
+	},
+	{
+		Version: 11,
+		Name:    "team avatar per path",
+		Steps: []Step{
+			// new avatar is stored next to old one until team points to it
+			sqliteDropTeamAvatarTeamUnique,
+			SQL(`CREATE INDEX IF NOT EXISTS team_avatar_team_id_index ON team_avatar (team_id)`),
+			SQL(`CREATE UNIQUE INDEX IF NOT EXISTS team_avatar_avatar_path_uindex ON team_avatar (avatar_path)`),
+		},
+	},
+}
+
+// sqliteDropTeamAvatarTeamUnique sqlite can not drop column constraint, table is rebuilt in one transaction.
+// It does nothing when team_id is not unique anymore.
+func sqliteDropTeamAvatarTeamUnique(ctx context.Context, conn *sql.Conn, dialect Dialect) error {
+	query := `
+SELECT COUNT(1) FROM pragma_index_list('team_avatar') AS l, pragma_index_info(l.name) AS i WHERE l."unique" = 1 AND i.name = 'team_id'
+`
+	var count int
+	if err := conn.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, query := range []string{`
+CREATE TABLE team_avatar_new
+(
+	id integer primary key autoincrement,
+	team_id int not null,
+	avatar_path varchar(64) not null,
+	avatar blob not null,
+	constraint team_avatar_team_id_fk
+		foreign key (team_id) references team (id)
+)`, `
+INSERT INTO team_avatar_new (id, team_id, avatar_path, avatar) SELECT id, team_id, avatar_path, avatar FROM team_avatar
+`, `
+DROP TABLE team_avatar
+`, `
+ALTER TABLE team_avatar_new RENAME TO team_avatar
+`} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"strings"
+)
+
+// migrateLockName is mysql named lock, so only one replica migrates at time
+const migrateLockName = "ctfplatform_schema_migrate"
+const migrateLockTimeout = 60
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return DialectMySQL
+}
+
+func (mysqlDialect) OnConflictUpdate(conflict []string, update []string) string {
+	set := make([]string, len(update))
+	for i, column := range update {
+		set[i] = fmt.Sprintf("%s = VALUES(%s)", column, column)
+	}
+	return "ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+func (mysqlDialect) open(dsn string) (*sql.DB, error) {
+	return sql.Open("mysql", dsn)
+}
+
+func (mysqlDialect) rebind(query string) string {
+	return query
+}
+
+func (mysqlDialect) args(args []interface{}) []interface{} {
+	return args
+}
+
+func (mysqlDialect) insert(ctx context.Context, db querier, query string, args []interface{}) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (mysqlDialect) wrapErr(err error) error {
+	me, ok := err.(*mysql.MySQLError)
+	if !ok {
+		return err
+	}
+	if me.Number == 1062 {
+		return ErrAlreadyExistsDB
+	}
+	if me.Number == 1451 {
+		return ErrReferencedDB
+	}
+	return err
+}
+
+func (mysqlDialect) isMissingTable(err error) bool {
+	me, ok := err.(*mysql.MySQLError)
+	return ok && me.Number == 1146
+}
+
+func (mysqlDialect) columnExists(ctx context.Context, conn *sql.Conn, table string, column string) (bool, error) {
+	query := `
+SELECT COUNT(1) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?
+`
+	var count int
+	if err := conn.QueryRowContext(ctx, query, table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (mysqlDialect) indexExists(ctx context.Context, conn *sql.Conn, table string, index string) (bool, error) {
+	query := `
+SELECT COUNT(1) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?
+`
+	var count int
+	if err := conn.QueryRowContext(ctx, query, table, index).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// lock named lock belongs to connection, so migration has to use the same conn
+func (mysqlDialect) lock(ctx context.Context, conn *sql.Conn) error {
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrateLockName, migrateLockTimeout).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return errors.New("timeout")
+	}
+	return nil
+}
+
+func (mysqlDialect) unlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `SELECT RELEASE_LOCK(?)`, migrateLockName)
+	return err
+}
+
+func (mysqlDialect) migrations() []Migration {
+	return mysqlMigrations
+}
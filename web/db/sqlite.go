@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strings"
+	"time"
+)
+
+// sqliteTimeFormat is also used by driver for time.Time args (_time_format=sqlite), so times compare as text
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+func init() {
+	// mysql functions used by models
+	sqlite.MustRegisterScalarFunction("now", 0, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format(sqliteTimeFormat), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("log2", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		x, err := sqliteFloat(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return math.Log2(x), nil
+	})
+	sqlite.MustRegisterDeterministicScalarFunction("greatest", -1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		var out driver.Value
+		var outFloat float64
+		for _, arg := range args {
+			if arg == nil {
+				return nil, nil
+			}
+			x, err := sqliteFloat(arg)
+			if err != nil {
+				return nil, err
+			}
+			if out == nil || x > outFloat {
+				out, outFloat = arg, x
+			}
+		}
+		return out, nil
+	})
+}
+
+func sqliteFloat(v driver.Value) (float64, error) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	}
+	return 0, fmt.Errorf("not a number: %T", v)
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return DialectSQLite
+}
+
+func (sqliteDialect) OnConflictUpdate(conflict []string, update []string) string {
+	set := make([]string, len(update))
+	for i, column := range update {
+		set[i] = fmt.Sprintf("%s = excluded.%s", column, column)
+	}
+	return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s", strings.Join(conflict, ", "), strings.Join(set, ", "))
+}
+
+// open dsn is file path or ":memory:", memory database lives only in single connection
+func (sqliteDialect) open(dsn string) (*sql.DB, error) {
+	path := dsn
+	params := ""
+	if i := strings.Index(dsn, "?"); i >= 0 {
+		path, params = dsn[:i], dsn[i+1:]
+	}
+	// immediate transactions wait for write lock at BEGIN instead of failing on first write
+	options := []string{"_pragma=foreign_keys(1)", "_pragma=busy_timeout(5000)", "_time_format=sqlite", "_txlock=immediate"}
+	if path != ":memory:" {
+		options = append(options, "_pragma=journal_mode(WAL)")
+	}
+	if len(params) > 0 {
+		options = append(options, params)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+strings.Join(options, "&"))
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		db.SetMaxOpenConns(1)
+		db.SetConnMaxLifetime(0)
+		db.SetMaxIdleConns(1)
+	}
+	return db, nil
+}
+
+func (sqliteDialect) rebind(query string) string {
+	return query
+}
+
+// args times are stored as text, they compare correctly only in the same zone
+func (sqliteDialect) args(args []interface{}) []interface{} {
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case time.Time:
+			args[i] = t.UTC()
+		case *time.Time:
+			if t != nil {
+				args[i] = t.UTC()
+			}
+		}
+	}
+	return args
+}
+
+func (sqliteDialect) insert(ctx context.Context, db querier, query string, args []interface{}) (int64, error) {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (sqliteDialect) wrapErr(err error) error {
+	var se *sqlite.Error
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch se.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return ErrAlreadyExistsDB
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return ErrReferencedDB
+	}
+	return err
+}
+
+func (sqliteDialect) isMissingTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such table")
+}
+
+func (sqliteDialect) columnExists(ctx context.Context, conn *sql.Conn, table string, column string) (bool, error) {
+	query := `
+SELECT COUNT(1) FROM pragma_table_info(?) WHERE name = ?
+`
+	var count int
+	if err := conn.QueryRowContext(ctx, query, table, column).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (sqliteDialect) indexExists(ctx context.Context, conn *sql.Conn, table string, index string) (bool, error) {
+	query := `
+SELECT COUNT(1) FROM pragma_index_list(?) WHERE name = ?
+`
+	var count int
+	if err := conn.QueryRowContext(ctx, query, table, index).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// lock sqlite is used by single process, database file is locked by sqlite itself
+func (sqliteDialect) lock(ctx context.Context, conn *sql.Conn) error {
+	return nil
+}
+
+func (sqliteDialect) unlock(ctx context.Context, conn *sql.Conn) error {
+	return nil
+}
+
+func (sqliteDialect) migrations() []Migration {
+	return sqliteMigrations
+}
@@ -0,0 +1,119 @@
+package db_test
+
+import (
+	"context"
+	"ctfplatform/db"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T, dsn string) *db.DatabaseInternal {
+	t.Helper()
+	dbSrv, err := db.NewDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbSrv.Close(context.Background()) })
+	if _, err := dbSrv.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return dbSrv
+}
+
+func TestSQLiteFunctions(t *testing.T) {
+	ctx := context.Background()
+	dbSrv := newTestDB(t, "sqlite://:memory:")
+
+	var greatest, greatestInt, log2 float64
+	var greatestNull sql.NullFloat64
+	var now string
+	query := `
+SELECT GREATEST(1, 2.5, 2), GREATEST(3, 1), LOG2(8), GREATEST(1, NULL), NOW()
+`
+	if err := dbSrv.QueryRow(ctx, query).Scan(&greatest, &greatestInt, &log2, &greatestNull, &now); err != nil {
+		t.Fatal(err)
+	}
+	if greatest != 2.5 || greatestInt != 3 || log2 != 3 || greatestNull.Valid {
+		t.Fatalf("greatest %v, %v, log2 %v, greatest with null %v", greatest, greatestInt, log2, greatestNull)
+	}
+	if _, err := time.Parse("2006-01-02 15:04:05.999999999-07:00", now); err != nil {
+		t.Fatalf("now %q: %v", now, err)
+	}
+}
+
+func TestSQLiteErrors(t *testing.T) {
+	ctx := context.Background()
+	dbSrv := newTestDB(t, "sqlite://:memory:")
+
+	query := `
+INSERT INTO task (name, description, category, difficult) VALUES ('task', '', 'web', 'easy')
+`
+	taskID, err := dbSrv.Insert(ctx, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query = `
+INSERT INTO task_flags (task_id, flag) VALUES (?, ?)
+`
+	if _, err := dbSrv.Exec(ctx, query, taskID, "ctf{a}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dbSrv.Exec(ctx, query, taskID, "ctf{a}"); err != db.ErrAlreadyExistsDB {
+		t.Fatalf("duplicated flag: %v", err)
+	}
+	if _, err := dbSrv.Exec(ctx, query, taskID+1, "ctf{b}"); err != db.ErrReferencedDB {
+		t.Fatalf("flag of missing task: %v", err)
+	}
+	if _, err := dbSrv.Exec(ctx, `DELETE FROM task WHERE id = ?`, taskID); err != db.ErrReferencedDB {
+		t.Fatalf("delete task with flags: %v", err)
+	}
+}
+
+// TestSQLiteFile concurrent transactions of file database wait for each other instead of failing as busy
+func TestSQLiteFile(t *testing.T) {
+	ctx := context.Background()
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "ctf.db")
+	dbSrv := newTestDB(t, dsn)
+
+	const workers, increments = 4, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < increments; j++ {
+				err := dbSrv.Tx(ctx, func(ctx context.Context) error {
+					var version int
+					if err := dbSrv.QueryRow(ctx, `SELECT version FROM cache_version WHERE name = 'flags'`).Scan(&version); err != nil {
+						return err
+					}
+					_, err := dbSrv.Exec(ctx, `UPDATE cache_version SET version = ? WHERE name = 'flags'`, version+1)
+					return err
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// data is kept in file, new connection sees it
+	reopened := newTestDB(t, dsn)
+	var version int
+	if err := reopened.QueryRow(ctx, `SELECT version FROM cache_version WHERE name = 'flags'`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != workers*increments {
+		t.Fatalf("version %d, want %d", version, workers*increments)
+	}
+}
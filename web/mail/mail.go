@@ -0,0 +1,144 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"ctfplatform/log"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/smtp"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type SMTPMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+func NewSMTPMailer(addr, username, password, from string) (*SMTPMailer, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("smtp addr: %w", err)
+	}
+	return &SMTPMailer{
+		addr:     addr,
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	}
+	if len(m.username) > 0 {
+		// smtp.PlainAuth refuses to send credentials over plain connection (except localhost)
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(m.from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.build(msg)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (m *SMTPMailer) build(msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + m.from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + msg.Subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// LogMailer only writes mails to the log, useful for local development
+type LogMailer struct{}
+
+// linkQueryRe matches query of links, they carry reset and verification tokens
+var linkQueryRe = regexp.MustCompile(`(https?://[^\s?]+)\?\S+`)
+
+// redactLinks logs are shipped and kept longer than tokens are valid, nobody reading them should log in as team
+func redactLinks(body string) string {
+	return linkQueryRe.ReplaceAllString(body, "$1?[redacted]")
+}
+
+func (m LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Log.WithFields(logrus.Fields{
+		"email":   msg.To,
+		"subject": msg.Subject,
+	}).Info(redactLinks(msg.Body))
+	return nil
+}
+
+// NewMailer returns nil mailer when backend is not configured
+func NewMailer(backend, smtpAddr, username, password, from string) (Mailer, error) {
+	switch backend {
+	case "smtp":
+		if len(smtpAddr) == 0 {
+			return nil, nil
+		}
+		m, err := NewSMTPMailer(smtpAddr, username, password, from)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "log":
+		return LogMailer{}, nil
+	case "none", "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown mail backend: %s", backend)
+}
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"context"
+	"ctfplatform/mail/smtptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPMailerSend(t *testing.T) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	m, err := NewSMTPMailer(srv.Addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = m.Send(ctx, Message{
+		To:      "team@example.com",
+		Subject: "Hello",
+		Body:    "first line\nsecond line\n.\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mails := srv.Mails()
+	if len(mails) != 1 {
+		t.Fatalf("got %d mails, want 1", len(mails))
+	}
+	got := mails[0]
+	if got.From != "noreply@ctf.local" || len(got.To) != 1 || got.To[0] != "team@example.com" {
+		t.Fatalf("envelope %s -> %v", got.From, got.To)
+	}
+	for _, want := range []string{"Subject: Hello\r\n", "To: team@example.com\r\n", "\r\n\r\nfirst line\r\nsecond line\r\n.\r\n"} {
+		if !strings.Contains(got.Data+"\r\n", want) {
+			t.Errorf("mail data does not contain %q:\n%s", want, got.Data)
+		}
+	}
+}
+
+func TestSMTPMailerSendUnavailable(t *testing.T) {
+	srv, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := srv.Addr
+	srv.Close()
+
+	m, err := NewSMTPMailer(addr, "", "", "noreply@ctf.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Send(ctx, Message{To: "team@example.com", Subject: "Hello", Body: "x"}); err == nil {
+		t.Fatal("send to closed server succeeded")
+	}
+}
+
+func TestRedactLinks(t *testing.T) {
+	body := "open the link below:\n\nhttps://ctf.local/reset-password?token=abc%2Bdef\n\nor http://ctf.local/api/v1/team/verify?token=xyz&x=1.\n"
+	got := redactLinks(body)
+	if strings.Contains(got, "abc") || strings.Contains(got, "xyz") {
+		t.Fatalf("token not redacted:\n%s", got)
+	}
+	if !strings.Contains(got, "https://ctf.local/reset-password?[redacted]") {
+		t.Fatalf("link path was lost:\n%s", got)
+	}
+}
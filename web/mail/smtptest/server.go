@@ -0,0 +1,129 @@
+// Package smtptest is local smtp stand-in for tests, it accepts every mail and keeps it in memory
+package smtptest
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+type Mail struct {
+	From string
+	To   []string
+	// Data is message with headers, line endings are "\r\n"
+	Data string
+}
+
+type Server struct {
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	mails    []Mail
+	received chan struct{}
+}
+
+// NewServer listens on random local port, it does not offer STARTTLS nor AUTH
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		received: make(chan struct{}, 100),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Mails returns copy of received mails
+func (s *Server) Mails() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mails...)
+}
+
+// Received is signaled after every accepted mail
+func (s *Server) Received() <-chan struct{} {
+	return s.received
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(textproto.NewConn(conn))
+		}()
+	}
+}
+
+func (s *Server) handle(conn *textproto.Conn) {
+	var mail Mail
+	conn.PrintfLine("220 smtptest ready")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			conn.PrintfLine("250 smtptest")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			mail = Mail{From: trimAddr(line[len("MAIL FROM:"):])}
+			conn.PrintfLine("250 ok")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			mail.To = append(mail.To, trimAddr(line[len("RCPT TO:"):]))
+			conn.PrintfLine("250 ok")
+		case cmd == "DATA":
+			conn.PrintfLine("354 end with <CRLF>.<CRLF>")
+			lines, err := conn.ReadDotLines()
+			if err != nil {
+				return
+			}
+			mail.Data = strings.Join(lines, "\r\n")
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			conn.PrintfLine("250 queued")
+			select {
+			case s.received <- struct{}{}:
+			default:
+			}
+		case cmd == "RSET", cmd == "NOOP":
+			conn.PrintfLine("250 ok")
+		case cmd == "QUIT":
+			conn.PrintfLine("221 bye")
+			return
+		default:
+			conn.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// trimAddr "<a@b> SIZE=1" -> "a@b"
+func trimAddr(value string) string {
+	value = strings.TrimSpace(value)
+	if i := strings.IndexByte(value, ' '); i >= 0 {
+		value = value[:i]
+	}
+	return strings.Trim(value, "<>")
+}
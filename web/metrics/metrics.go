@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"time"
+)
+
+const namespace = "ctfplatform"
+
+var (
+	// route is pattern from router, not raw path, so ids do not explode label count
+	HttpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Handled http requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	HttpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Http request latency by route.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2, 4, 8},
+	}, []string{"route"})
+	HttpTimeouts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_request_timeouts_total",
+		Help:      "Http requests which exceeded request timeout.",
+	}, []string{"route"})
+	HttpPanics = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_panics_total",
+		Help:      "Panics recovered from http handlers.",
+	})
+
+	FlagSubmissions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "flag_submissions_total",
+		Help:      "Flag submissions by result.",
+	}, []string{"result"})
+	Registrations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Registered teams.",
+	})
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Login attempts by result.",
+	}, []string{"result"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Database query latency by operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2},
+	}, []string{"op"})
+)
+
+const (
+	FlagCorrect       = "correct"
+	FlagInvalid       = "invalid"
+	FlagAlreadySolved = "already_solved"
+	FlagRejected      = "rejected"
+	FlagError         = "error"
+
+	LoginOk      = "ok"
+	LoginInvalid = "invalid"
+	LoginBlocked = "blocked"
+	LoginError   = "error"
+)
+
+// ObserveDB records query latency since start
+func ObserveDB(op string, start time.Time) {
+	DBQueryDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+// RegisterDBStats exposes connection pool stats, stats is called on every scrape
+func RegisterDBStats(stats func() sql.DBStats) {
+	gauge := func(name string, help string, value func(s sql.DBStats) float64) {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return value(stats())
+		})
+	}
+	counter := func(name string, help string, value func(s sql.DBStats) float64) {
+		promauto.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      name,
+			Help:      help,
+		}, func() float64 {
+			return value(stats())
+		})
+	}
+
+	gauge("db_max_open_connections", "Maximum number of open connections to the database.", func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("db_open_connections", "Established connections both in use and idle.", func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("db_in_use_connections", "Connections currently in use.", func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("db_idle_connections", "Idle connections.", func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("db_wait_count_total", "Connections waited for.", func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("db_wait_duration_seconds_total", "Time blocked waiting for a new connection.", func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+}
+
+// RegisterFlagCache exposes age of flags cache, checkedAt is zero before first reload
+func RegisterFlagCache(checkedAt func() time.Time) {
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "flag_cache_age_seconds",
+		Help:      "Seconds since flags cache was confirmed up to date, -1 when never loaded.",
+	}, func() float64 {
+		t := checkedAt()
+		if t.IsZero() {
+			return -1
+		}
+		return time.Since(t).Seconds()
+	})
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type TeamPasswordResetXXX struct {
+	ID        int
+	TeamID    int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// GetAllSessions returns also revoked sessions, used by data export
+func (s *TeamInternal) GetAllSessions(ctx context.Context, teamID int) ([]*TeamSessionXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	user_ip,
+	user_agent,
+	created_at,
+	last_seen_at,
+	revoked_at IS NOT NULL
+FROM team_session
+WHERE
+	team_id = ?
+ORDER BY id ASC
+`
+	rows, err := s.db.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TeamSessionXXX, 0)
+	for rows.Next() {
+		var row TeamSessionXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.UserIP, &row.UserAgent, &row.CreatedAt, &row.LastSeenAt, &row.Revoked); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+// GetAllTokens returns also revoked tokens, used by data export
+func (s *TeamInternal) GetAllTokens(ctx context.Context, teamID int) ([]*TeamTokenXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	name,
+	scope,
+	created_at,
+	last_used_at
+FROM team_token
+WHERE
+	team_id = ?
+ORDER BY id ASC
+`
+	rows, err := s.db.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TeamTokenXXX, 0)
+	for rows.Next() {
+		var row TeamTokenXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.Name, &row.Scope, &row.CreatedAt, &row.LastUsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *TeamInternal) GetPasswordResets(ctx context.Context, teamID int) ([]*TeamPasswordResetXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	expires_at,
+	used_at,
+	created_at
+FROM password_reset
+WHERE
+	team_id = ?
+ORDER BY id ASC
+`
+	rows, err := s.db.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TeamPasswordResetXXX, 0)
+	for rows.Next() {
+		var row TeamPasswordResetXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.ExpiresAt, &row.UsedAt, &row.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+// anonymized team is renamed to "deleted-<id>" with "deleted-<id>@deleted.invalid" email,
+// registration rejects them so the rename never collides
+const (
+	DeletedTeamNamePrefix  = "deleted-"
+	DeletedTeamEmailSuffix = "@deleted.invalid"
+)
+
+// AnonymizeTeam purges personal data, team row and its audit stay so points of other teams do not change.
+// State is kept on purpose: active team stays on scoreboard as "deleted-<id>", so places of other teams
+// do not move, banned or hidden team stays out of it. Empty password hash never matches, so team can not log in anymore.
+func (s *TeamInternal) AnonymizeTeam(ctx context.Context, teamID int) error {
+	return s.db.Tx(ctx, func(ctx context.Context) error {
+		for _, query := range []string{
+			`DELETE FROM team_token WHERE team_id = ?`,
+			`DELETE FROM team_session WHERE team_id = ?`,
+			`DELETE FROM password_reset WHERE team_id = ?`,
+			`DELETE FROM team_avatar WHERE team_id = ?`,
+			`DELETE FROM avatar_moderation WHERE team_id = ?`,
+		} {
+			if _, err := s.db.Exec(ctx, query, teamID); err != nil {
+				return err
+			}
+		}
+
+		query := `
+UPDATE team SET
+	name = ?,
+	email = ?,
+	password = '',
+	avatar = '',
+	country = '',
+	affiliation = '',
+	website = '',
+	session_version = session_version + 1,
+	deleted_at = NOW()
+WHERE id = ?
+`
+		name := fmt.Sprintf("%s%d", DeletedTeamNamePrefix, teamID)
+		_, err := s.db.Exec(ctx, query, name, name+DeletedTeamEmailSuffix, teamID)
+		return err
+	})
+}
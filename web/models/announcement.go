@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"ctfplatform/db"
+	"database/sql"
+	"time"
+)
+
+type AnnouncementXXX struct {
+	ID          int
+	Title       string
+	Description string
+	CreatedAt   time.Time
+}
+
+type AnnouncementInternal struct {
+	db *db.DatabaseInternal
+}
+
+func NewAnnouncementDB(db *db.DatabaseInternal) *AnnouncementInternal {
+	return &AnnouncementInternal{
+		db: db,
+	}
+}
+
+func (s *AnnouncementInternal) All(ctx context.Context) ([]*AnnouncementXXX, error) {
+	// TODO: limit sql?
+	query := `
+SELECT
+	id,
+	title,
+	description,
+	created_at
+FROM
+	announcement
+ORDER BY created_at DESC`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*AnnouncementXXX, 0)
+	for rows.Next() {
+		var row AnnouncementXXX
+		if err := rows.Scan(&row.ID, &row.Title, &row.Description, &row.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *AnnouncementInternal) GetByID(ctx context.Context, id int) (*AnnouncementXXX, error) {
+	query := `
+SELECT
+	id,
+	title,
+	description,
+	created_at
+FROM
+	announcement
+WHERE
+	id = ?
+`
+	var out AnnouncementXXX
+	err := s.db.QueryRow(ctx, query, id).Scan(&out.ID, &out.Title, &out.Description, &out.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *AnnouncementInternal) Add(ctx context.Context, announcement AnnouncementXXX) (int, error) {
+	query := `
+INSERT INTO announcement (title, description, created_at) VALUES (?, ?, NOW())
+`
+	return s.db.Insert(ctx, query, announcement.Title, announcement.Description)
+}
+
+func (s *AnnouncementInternal) Update(ctx context.Context, announcement AnnouncementXXX) error {
+	query := `
+UPDATE announcement SET title = ?, description = ? WHERE id = ?
+`
+	_, err := s.db.Exec(ctx, query, announcement.Title, announcement.Description, announcement.ID)
+	return err
+}
+
+func (s *AnnouncementInternal) Delete(ctx context.Context, id int) error {
+	query := `
+DELETE FROM announcement WHERE id = ?
+`
+	result, err := s.db.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"ctfplatform/config"
 	"ctfplatform/db"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -38,29 +39,80 @@ func NewAuditDB(db *db.DatabaseInternal) *AuditInternal {
 
 func (s *AuditInternal) AddSolve(ctx context.Context, teamID int, taskID int) error {
 	query := `
-INSERT INTO audit (id, team_id, task_id, created_at) VALUES (NULL, ?, ?, NOW())
+INSERT INTO audit (team_id, task_id, created_at) VALUES (?, ?, NOW())
 `
 	_, err := s.db.Exec(ctx, query, teamID, taskID)
 	return err
 }
 
-func (s *AuditInternal) GetAudits(ctx context.Context) ([]*AuditXXX, error) {
-	// TODO: limit sql?
-	query := `
+// AuditFilter zero values do not limit results
+type AuditFilter struct {
+	TeamID int
+	TaskID int
+	// created_at >= From and < To
+	From time.Time
+	To   time.Time
+	// solves made during (true) or outside (false) of freeze
+	Freeze *bool
+	// cursor, only solves older than given id
+	BeforeID int
+	Limit    int
+}
+
+// GetAudits returns newest solves first, also of hidden, banned and disqualified teams
+func (s *AuditInternal) GetAudits(ctx context.Context, filter AuditFilter) ([]*AuditXXX, error) {
+	where := []string{"1 = 1"}
+	args := make([]interface{}, 0)
+	if filter.TeamID != 0 {
+		where = append(where, "audit.team_id = ?")
+		args = append(args, filter.TeamID)
+	}
+	if filter.TaskID != 0 {
+		where = append(where, "audit.task_id = ?")
+		args = append(args, filter.TaskID)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "audit.created_at >= ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "audit.created_at < ?")
+		args = append(args, filter.To)
+	}
+	if filter.Freeze != nil {
+		freezeStart := time.Time(config.Config.FreezeStartCompetition)
+		freezeEnd := time.Time(config.Config.FreezeEndCompetition)
+		if *filter.Freeze {
+			where = append(where, "(audit.created_at >= ? AND audit.created_at < ?)")
+		} else {
+			where = append(where, "(audit.created_at < ? OR audit.created_at >= ?)")
+		}
+		args = append(args, freezeStart, freezeEnd)
+	}
+	if filter.BeforeID != 0 {
+		where = append(where, "audit.id < ?")
+		args = append(args, filter.BeforeID)
+	}
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
 SELECT
 	audit.id,
 	audit.team_id,
 	audit.task_id,
 	audit.created_at,
-	task.name as task_name,
-	team.name as team_name
+	team.name as team_name,
+	task.name as task_name
 FROM 
 	audit
 INNER JOIN team ON (team.id = audit.team_id)
 INNER JOIN task ON (task.id = audit.task_id)
-ORDER BY audit.created_at DESC
-`
-	rows, err := s.db.Query(ctx, query)
+WHERE
+	%s
+ORDER BY audit.id DESC
+LIMIT ?
+`, strings.Join(where, "\n\tAND "))
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -94,6 +146,7 @@ INNER JOIN task ON (task.id = audit.task_id)
 WHERE 
 	audit.task_id = ?
 	AND task.started_at < NOW()
+	AND team.state = 'active'
 ORDER BY audit.created_at ASC
 `
 	rows, err := s.db.Query(ctx, query, taskID)
@@ -187,16 +240,32 @@ func (s *AuditInternal) GetScoreboard(ctx context.Context) ([]*ScoreboardtXXX, e
 	// TODO: limit sql?
 	query := `
 WITH
-    audit_fixed AS (
+    audit_counted AS (
         SELECT
             audit.id,
             audit.team_id,
             audit.task_id,
-            audit.created_at
+            audit.created_at,
+            team.state,
+            team.active
         FROM
             audit
+        INNER JOIN team ON (team.id = audit.team_id)
         WHERE
             audit.created_at BETWEEN ? AND ?
+            AND (team.state IN ('active', 'hidden') OR (? AND team.state = 'banned'))
+    ),
+    audit_fixed AS (
+        SELECT
+            audit_counted.id,
+            audit_counted.team_id,
+            audit_counted.task_id,
+            audit_counted.created_at
+        FROM
+            audit_counted
+        WHERE
+            audit_counted.state = 'active'
+            AND (? OR audit_counted.active = TRUE)
     ),
     last_solved_task_per_team AS (
         SELECT
@@ -215,7 +284,7 @@ WITH
     ),
     task_calculated_points AS (
         SELECT
-            audit_fixed.task_id,
+            audit_counted.task_id,
             COUNT(1) as team_solved,
             GREATEST(
                 50,
@@ -226,13 +295,13 @@ WITH
                                 1,
                                 COUNT(1)
                             ) + 3
-                        ) / (1 + 3)
+                        ) / (1 + 3.0)
                     ))
                 )
             ) as points
         FROM
-            audit_fixed
-        GROUP BY audit_fixed.task_id
+            audit_counted
+        GROUP BY audit_counted.task_id
     ),
     team_calculated_points AS (
         SELECT
@@ -259,7 +328,7 @@ ORDER BY team_calculated_points.points DESC, last_solved_task_per_team.created_a
 	if config.IsFreezeNow() {
 		endDate = time.Time(config.Config.FreezeStartCompetition)
 	}
-	rows, err := s.db.Query(ctx, query, time.Time(config.Config.StartCompetition), endDate)
+	rows, err := s.db.Query(ctx, query, time.Time(config.Config.StartCompetition), endDate, config.Config.BannedTeamSolvesCount, config.Config.InactiveTeamOnScoreboard)
 	if err != nil {
 		return nil, err
 	}
@@ -276,38 +345,20 @@ ORDER BY team_calculated_points.points DESC, last_solved_task_per_team.created_a
 	return out, nil
 }
 
-// TODO: move to other file
-type AnnouncementXXX struct {
-	ID          int
-	Title       string
-	Description string
-	CreatedAt   time.Time
+func (s *AuditInternal) CountSolvedByTask(ctx context.Context, taskID int) (int, error) {
+	query := `
+SELECT COUNT(1) FROM audit WHERE task_id = ?
+`
+	var out int
+	err := s.db.QueryRow(ctx, query, taskID).Scan(&out)
+	return out, err
 }
 
-func (s *AuditInternal) GetAnnouncements(ctx context.Context) ([]*AnnouncementXXX, error) {
-	// TODO: limit sql?
+func (s *AuditInternal) CountSolvedByTeam(ctx context.Context, teamID int) (int, error) {
 	query := `
-SELECT
-	id,
-	title,
-	description,
-	created_at
-FROM
-	announcement
-ORDER BY created_at DESC`
-	rows, err := s.db.Query(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	out := make([]*AnnouncementXXX, 0)
-	for rows.Next() {
-		var row AnnouncementXXX
-		if err := rows.Scan(&row.ID, &row.Title, &row.Description, &row.CreatedAt); err != nil {
-			return nil, err
-		}
-		out = append(out, &row)
-	}
-	return out, nil
+SELECT COUNT(1) FROM audit WHERE team_id = ?
+`
+	var out int
+	err := s.db.QueryRow(ctx, query, teamID).Scan(&out)
+	return out, err
 }
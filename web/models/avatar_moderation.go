@@ -0,0 +1,158 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	AvatarModerationPending  = "pending"
+	AvatarModerationApproved = "approved"
+	AvatarModerationRejected = "rejected"
+)
+
+// AvatarModerationXXX avatar payload is kept only while pending, reviewed rows are history
+type AvatarModerationXXX struct {
+	ID         int
+	TeamID     int
+	TeamName   string
+	Avatar     []byte
+	State      string
+	Reason     string
+	Admin      string
+	CreatedAt  time.Time
+	ReviewedAt *time.Time
+}
+
+// AddAvatarModeration replaces pending avatar of team, only the last upload is reviewed
+func (s *TeamInternal) AddAvatarModeration(ctx context.Context, teamID int, avatarPayload []byte) (int, error) {
+	var id int
+	err := s.db.Tx(ctx, func(ctx context.Context) error {
+		query := `
+DELETE FROM avatar_moderation WHERE team_id = ? AND state = ?
+`
+		if _, err := s.db.Exec(ctx, query, teamID, AvatarModerationPending); err != nil {
+			return err
+		}
+
+		query = `
+INSERT INTO avatar_moderation (team_id, avatar, state, created_at) VALUES (?, ?, ?, NOW())
+`
+		var err error
+		id, err = s.db.Insert(ctx, query, teamID, avatarPayload, AvatarModerationPending)
+		return err
+	})
+	return id, err
+}
+
+// GetAvatarModerations returns rows without payload, oldest first so queue is reviewed in order
+func (s *TeamInternal) GetAvatarModerations(ctx context.Context, state string) ([]*AvatarModerationXXX, error) {
+	query := `
+SELECT
+	m.id,
+	m.team_id,
+	t.name,
+	m.state,
+	m.reason,
+	m.admin,
+	m.created_at,
+	m.reviewed_at
+FROM avatar_moderation m
+INNER JOIN team t ON t.id = m.team_id
+WHERE
+	m.state = ?
+ORDER BY m.id ASC
+`
+	rows, err := s.db.Query(ctx, query, state)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*AvatarModerationXXX, 0)
+	for rows.Next() {
+		var row AvatarModerationXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.TeamName, &row.State, &row.Reason, &row.Admin, &row.CreatedAt, &row.ReviewedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *TeamInternal) GetAvatarModeration(ctx context.Context, id int) (*AvatarModerationXXX, error) {
+	query := `
+SELECT
+	m.id,
+	m.team_id,
+	t.name,
+	m.avatar,
+	m.state,
+	m.reason,
+	m.admin,
+	m.created_at,
+	m.reviewed_at
+FROM avatar_moderation m
+INNER JOIN team t ON t.id = m.team_id
+WHERE
+	m.id = ?
+`
+	var out AvatarModerationXXX
+	err := s.db.QueryRow(ctx, query, id).Scan(&out.ID, &out.TeamID, &out.TeamName, &out.Avatar, &out.State, &out.Reason, &out.Admin, &out.CreatedAt, &out.ReviewedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetLastAvatarModeration returns sql.ErrNoRows when team never uploaded avatar under moderation
+func (s *TeamInternal) GetLastAvatarModeration(ctx context.Context, teamID int) (*AvatarModerationXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	state,
+	reason,
+	admin,
+	created_at,
+	reviewed_at
+FROM avatar_moderation
+WHERE
+	team_id = ?
+ORDER BY id DESC
+LIMIT 1
+`
+	var out AvatarModerationXXX
+	err := s.db.QueryRow(ctx, query, teamID).Scan(&out.ID, &out.TeamID, &out.State, &out.Reason, &out.Admin, &out.CreatedAt, &out.ReviewedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReviewAvatarModeration returns sql.ErrNoRows when row is not pending anymore, so two admins can not both decide
+func (s *TeamInternal) ReviewAvatarModeration(ctx context.Context, id int, admin string, state string, reason string) error {
+	query := `
+UPDATE avatar_moderation SET
+	avatar = ?,
+	state = ?,
+	reason = ?,
+	admin = ?,
+	reviewed_at = NOW()
+WHERE
+	id = ? AND state = ?
+`
+	result, err := s.db.Exec(ctx, query, []byte{}, state, reason, admin, id, AvatarModerationPending)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
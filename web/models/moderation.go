@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type TeamModerationXXX struct {
+	ID        int
+	TeamID    int
+	Admin     string
+	State     string
+	Reason    string
+	CreatedAt time.Time
+}
+
+func (s *TeamInternal) AddModeration(ctx context.Context, teamID int, admin string, state string, reason string) (int, error) {
+	query := `
+INSERT INTO team_moderation (team_id, admin, state, reason, created_at) VALUES (?, ?, ?, ?, NOW())
+`
+	return s.db.Insert(ctx, query, teamID, admin, state, reason)
+}
+
+func (s *TeamInternal) GetModerations(ctx context.Context, teamID int) ([]*TeamModerationXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	admin,
+	state,
+	reason,
+	created_at
+FROM team_moderation
+WHERE
+	team_id = ?
+ORDER BY id DESC
+`
+	rows, err := s.db.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TeamModerationXXX, 0)
+	for rows.Next() {
+		var row TeamModerationXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.Admin, &row.State, &row.Reason, &row.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
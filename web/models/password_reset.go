@@ -0,0 +1,70 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (s *TeamInternal) AddPasswordReset(ctx context.Context, teamID int, tokenHash string, expiresAt time.Time) error {
+	query := `
+INSERT INTO password_reset (team_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, NOW())
+`
+	_, err := s.db.Exec(ctx, query, teamID, tokenHash, expiresAt)
+	return err
+}
+
+// UsePasswordReset marks token as used and returns team id, every token can be used only once.
+// It should run in transaction together with password update.
+func (s *TeamInternal) UsePasswordReset(ctx context.Context, tokenHash string) (int, error) {
+	query := `
+UPDATE password_reset SET used_at = NOW() WHERE token_hash = ? AND used_at IS NULL AND expires_at > NOW()
+`
+	result, err := s.db.Exec(ctx, query, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected != 1 {
+		return 0, sql.ErrNoRows
+	}
+
+	query = `
+SELECT team_id FROM password_reset WHERE token_hash = ?
+`
+	var teamID int
+	if err := s.db.QueryRow(ctx, query, tokenHash).Scan(&teamID); err != nil {
+		return 0, err
+	}
+
+	// other links sent in meantime are not valid anymore
+	query = `
+UPDATE password_reset SET used_at = NOW() WHERE team_id = ? AND used_at IS NULL
+`
+	if _, err := s.db.Exec(ctx, query, teamID); err != nil {
+		return 0, err
+	}
+	return teamID, nil
+}
+
+func (s *TeamInternal) UpdatePassword(ctx context.Context, teamID int, password string) error {
+	query := `
+UPDATE team SET password = ? WHERE id = ?
+`
+	_, err := s.db.Exec(ctx, query, password, teamID)
+	return err
+}
+
+func (s *TeamInternal) GetSessionVersion(ctx context.Context, teamID int) (int, error) {
+	query := `
+SELECT session_version FROM team WHERE id = ?
+`
+	var version int
+	if err := s.db.QueryRow(ctx, query, teamID).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type TeamSessionXXX struct {
+	ID         int
+	TeamID     int
+	UserIP     string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	Revoked    bool
+
+	TeamSessionVersion int
+}
+
+func (s *TeamInternal) AddSession(ctx context.Context, teamID int, userIP string, userAgent string) (int, error) {
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	query := `
+INSERT INTO team_session (team_id, user_ip, user_agent, created_at, last_seen_at) VALUES (?, ?, ?, NOW(), NOW())
+`
+	return s.db.Insert(ctx, query, teamID, userIP, userAgent)
+}
+
+func (s *TeamInternal) GetSession(ctx context.Context, teamID int, sessionID int) (*TeamSessionXXX, error) {
+	query := `
+SELECT
+	team_session.id,
+	team_session.team_id,
+	team_session.user_ip,
+	team_session.user_agent,
+	team_session.created_at,
+	team_session.last_seen_at,
+	team_session.revoked_at IS NOT NULL,
+	team.session_version
+FROM team_session
+INNER JOIN team ON (team.id = team_session.team_id)
+WHERE
+	team_session.id = ?
+	AND team_session.team_id = ?
+`
+	var out TeamSessionXXX
+	err := s.db.QueryRow(ctx, query, sessionID, teamID).Scan(&out.ID, &out.TeamID, &out.UserIP, &out.UserAgent, &out.CreatedAt, &out.LastSeenAt, &out.Revoked, &out.TeamSessionVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *TeamInternal) GetSessions(ctx context.Context, teamID int) ([]*TeamSessionXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	user_ip,
+	user_agent,
+	created_at,
+	last_seen_at
+FROM team_session
+WHERE
+	team_id = ?
+	AND revoked_at IS NULL
+ORDER BY last_seen_at DESC
+`
+	rows, err := s.db.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TeamSessionXXX, 0)
+	for rows.Next() {
+		var row TeamSessionXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.UserIP, &row.UserAgent, &row.CreatedAt, &row.LastSeenAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *TeamInternal) TouchSession(ctx context.Context, sessionID int) error {
+	query := `
+UPDATE team_session SET last_seen_at = NOW() WHERE id = ?
+`
+	_, err := s.db.Exec(ctx, query, sessionID)
+	return err
+}
+
+func (s *TeamInternal) RevokeSession(ctx context.Context, teamID int, sessionID int) error {
+	query := `
+UPDATE team_session SET revoked_at = NOW() WHERE id = ? AND team_id = ? AND revoked_at IS NULL
+`
+	result, err := s.db.Exec(ctx, query, sessionID, teamID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeSessions revokes all sessions except exceptSessionID (0 revokes all) and bumps session version,
+// so also old sessions without id are not valid anymore. Returns new session version.
+func (s *TeamInternal) RevokeSessions(ctx context.Context, teamID int, exceptSessionID int) (int, error) {
+	var version int
+	err := s.db.Tx(ctx, func(ctx context.Context) error {
+		query := `
+UPDATE team SET session_version = session_version + 1 WHERE id = ?
+`
+		if _, err := s.db.Exec(ctx, query, teamID); err != nil {
+			return err
+		}
+
+		query = `
+UPDATE team_session SET revoked_at = NOW() WHERE team_id = ? AND id != ? AND revoked_at IS NULL
+`
+		if _, err := s.db.Exec(ctx, query, teamID, exceptSessionID); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = s.GetSessionVersion(ctx, teamID)
+		return err
+	})
+	return version, err
+}
@@ -5,6 +5,7 @@ import (
 	"ctfplatform/config"
 	"ctfplatform/db"
 	"ctfplatform/log"
+	"database/sql"
 	"errors"
 	"sync"
 	"time"
@@ -12,21 +13,41 @@ import (
 
 type TaskXXX struct {
 	ID          int
+	Slug        *string
 	Name        string
 	Points      int
 	Solvers     int
 	Category    string
 	Description string
 	Difficult   string
+	StartedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+type TaskFlagXXX struct {
+	ID     int
+	TaskID int
+	Flag   string
 }
 
 type TaskInternal struct {
 	db *db.DatabaseInternal
 
-	flagsMu     sync.RWMutex
-	flagsToTask map[string]int
+	flagsMu         sync.RWMutex
+	flagsToTask     map[string]int
+	flagsVersion    int64
+	flagsReloadedAt time.Time
+	// last time flags version was checked and cache was up to date
+	flagsCheckedAt time.Time
+	// flags are reloaded right when next task starts
+	nextStartAt *time.Time
 }
 
+const flagsCacheName = "flags"
+
+// minimal wait between checks, app and db clocks can differ so started task can be still not visible
+const flagsMinWait = 100 * time.Millisecond
+
 func NewTaskDB(db *db.DatabaseInternal) *TaskInternal {
 	s := &TaskInternal{
 		db:          db,
@@ -35,28 +56,145 @@ func NewTaskDB(db *db.DatabaseInternal) *TaskInternal {
 	return s
 }
 
+// Run checks flags version every FlagCachePollInterval and wakes up when next task starts
 func (s *TaskInternal) Run(ctx context.Context) error {
 	for {
-		s.updateWorker(ctx)
+		wait := s.updateWorker(ctx)
 
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(time.Second * 10):
+		case <-time.After(wait):
 		}
 	}
-	return nil
 }
 
-func (s *TaskInternal) updateWorker(ctx context.Context) {
+// updateWorker reloads flags when version changed or next task started, returns time to next check
+func (s *TaskInternal) updateWorker(ctx context.Context) time.Duration {
+	wait := config.Config.FlagCachePollInterval
+
+	version, err := s.GetFlagsVersion(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Log.WithError(err).Error("not updating flags")
+		}
+		return wait
+	}
+
+	s.flagsMu.RLock()
+	stale := s.flagsReloadedAt.IsZero() || s.flagsVersion != version || (s.nextStartAt != nil && !time.Now().Before(*s.nextStartAt))
+	s.flagsMu.RUnlock()
+
+	if stale {
+		if err := s.reload(ctx, version); err != nil {
+			if ctx.Err() == nil {
+				log.Log.WithError(err).Error("not updating flags")
+			}
+			return wait
+		}
+	} else {
+		s.flagsMu.Lock()
+		s.flagsCheckedAt = time.Now()
+		s.flagsMu.Unlock()
+	}
+
+	s.flagsMu.RLock()
+	nextStartAt := s.nextStartAt
+	s.flagsMu.RUnlock()
+	if nextStartAt != nil {
+		if untilStart := time.Until(*nextStartAt); untilStart < wait {
+			wait = untilStart
+		}
+		if wait < flagsMinWait {
+			wait = flagsMinWait
+		}
+	}
+	return wait
+}
+
+// Reload refreshes flags cache used by GetByFlag
+func (s *TaskInternal) Reload(ctx context.Context) error {
+	version, err := s.GetFlagsVersion(ctx)
+	if err != nil {
+		return err
+	}
+	return s.reload(ctx, version)
+}
+
+// reload version is read before flags, change made during reload is picked up by next check
+func (s *TaskInternal) reload(ctx context.Context, version int64) error {
 	newFlags, err := s.GetFlags(ctx)
 	if err != nil {
-		log.Log.WithError(err).Error("not updating flags")
-		return
+		return err
 	}
+	nextStartAt, err := s.GetNextStartAt(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
 	s.flagsMu.Lock()
 	s.flagsToTask = newFlags
+	s.flagsVersion = version
+	s.flagsReloadedAt = now
+	s.flagsCheckedAt = now
+	s.nextStartAt = nextStartAt
 	s.flagsMu.Unlock()
+	return nil
+}
+
+type FlagsCacheState struct {
+	Version     int64
+	Flags       int
+	ReloadedAt  time.Time
+	CheckedAt   time.Time
+	NextStartAt *time.Time
+}
+
+func (s *TaskInternal) FlagsCacheState() FlagsCacheState {
+	s.flagsMu.RLock()
+	defer s.flagsMu.RUnlock()
+	return FlagsCacheState{
+		Version:     s.flagsVersion,
+		Flags:       len(s.flagsToTask),
+		ReloadedAt:  s.flagsReloadedAt,
+		CheckedAt:   s.flagsCheckedAt,
+		NextStartAt: s.nextStartAt,
+	}
+}
+
+func (s *TaskInternal) GetFlagsVersion(ctx context.Context) (int64, error) {
+	query := `
+SELECT version FROM cache_version WHERE name = ?
+`
+	var version int64
+	if err := s.db.QueryRow(ctx, query, flagsCacheName).Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// BumpFlagsVersion makes every instance reload flags on its next check
+func (s *TaskInternal) BumpFlagsVersion(ctx context.Context) error {
+	query := `
+UPDATE cache_version SET version = version + 1 WHERE name = ?
+`
+	_, err := s.db.Exec(ctx, query, flagsCacheName)
+	return err
+}
+
+// GetNextStartAt returns started_at of first task which is not started yet, nil when there is none
+func (s *TaskInternal) GetNextStartAt(ctx context.Context) (*time.Time, error) {
+	query := `
+SELECT started_at FROM task WHERE started_at >= NOW() ORDER BY started_at LIMIT 1
+`
+	var startedAt time.Time
+	if err := s.db.QueryRow(ctx, query).Scan(&startedAt); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &startedAt, nil
 }
 
 func (s *TaskInternal) GetFlags(ctx context.Context) (map[string]int, error) {
@@ -111,8 +249,10 @@ WITH
             audit.created_at
         FROM
             audit
+        INNER JOIN team ON (team.id = audit.team_id)
         WHERE
             audit.created_at BETWEEN ? AND ?
+            AND (team.state IN ('active', 'hidden') OR (? AND team.state = 'banned'))
     ),
     task_calculated_points AS (
         SELECT
@@ -127,7 +267,7 @@ WITH
                                 1,
                                 COUNT(1)
                             ) + 3
-                        ) / (1 + 3)
+                        ) / (1 + 3.0)
                     ))
                 )
             ) as points
@@ -149,7 +289,7 @@ LEFT JOIN task_calculated_points ON (task_calculated_points.task_id = task.id)
 WHERE
 	task.started_at < NOW()
 `
-	rows, err := s.db.Query(ctx, query, time.Time(config.Config.StartCompetition), time.Time(config.Config.EndCompetition))
+	rows, err := s.db.Query(ctx, query, time.Time(config.Config.StartCompetition), time.Time(config.Config.EndCompetition), config.Config.BannedTeamSolvesCount)
 	if err != nil {
 		return nil, err
 	}
@@ -165,3 +305,168 @@ WHERE
 	}
 	return out, nil
 }
+
+// admin
+
+func (s *TaskInternal) AdminAll(ctx context.Context) ([]*TaskXXX, error) {
+	query := `
+SELECT
+	id,
+	slug,
+	name,
+	description,
+	category,
+	difficult,
+	started_at,
+	created_at
+FROM task
+ORDER BY id ASC
+`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TaskXXX, 0)
+	for rows.Next() {
+		var row TaskXXX
+		if err := rows.Scan(&row.ID, &row.Slug, &row.Name, &row.Description, &row.Category, &row.Difficult, &row.StartedAt, &row.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *TaskInternal) GetByID(ctx context.Context, id int) (*TaskXXX, error) {
+	query := `
+SELECT
+	id,
+	slug,
+	name,
+	description,
+	category,
+	difficult,
+	started_at,
+	created_at
+FROM task
+WHERE
+	id = ?
+`
+	var out TaskXXX
+	err := s.db.QueryRow(ctx, query, id).Scan(&out.ID, &out.Slug, &out.Name, &out.Description, &out.Category, &out.Difficult, &out.StartedAt, &out.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *TaskInternal) GetBySlug(ctx context.Context, slug string) (*TaskXXX, error) {
+	query := `
+SELECT
+	id,
+	slug,
+	name,
+	description,
+	category,
+	difficult,
+	started_at,
+	created_at
+FROM task
+WHERE
+	slug = ?
+`
+	var out TaskXXX
+	err := s.db.QueryRow(ctx, query, slug).Scan(&out.ID, &out.Slug, &out.Name, &out.Description, &out.Category, &out.Difficult, &out.StartedAt, &out.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *TaskInternal) AddTask(ctx context.Context, task TaskXXX) (int, error) {
+	query := `
+INSERT INTO task (slug, name, description, category, difficult, started_at, created_at) VALUES (?, ?, ?, ?, ?, ?, NOW())
+`
+	return s.db.Insert(ctx, query, task.Slug, task.Name, task.Description, task.Category, task.Difficult, task.StartedAt)
+}
+
+func (s *TaskInternal) UpdateTask(ctx context.Context, task TaskXXX) error {
+	query := `
+UPDATE task SET slug = ?, name = ?, description = ?, category = ?, difficult = ?, started_at = ? WHERE id = ?
+`
+	_, err := s.db.Exec(ctx, query, task.Slug, task.Name, task.Description, task.Category, task.Difficult, task.StartedAt, task.ID)
+	return err
+}
+
+// DeleteTask removes task with its flags, task with solves can not be removed (audit foreign key)
+func (s *TaskInternal) DeleteTask(ctx context.Context, id int) error {
+	return s.db.Tx(ctx, func(ctx context.Context) error {
+		query := `
+DELETE FROM task_flags WHERE task_id = ?
+`
+		if _, err := s.db.Exec(ctx, query, id); err != nil {
+			return err
+		}
+
+		query = `
+DELETE FROM task WHERE id = ?
+`
+		_, err := s.db.Exec(ctx, query, id)
+		return err
+	})
+}
+
+func (s *TaskInternal) GetTaskFlags(ctx context.Context, taskID int) ([]*TaskFlagXXX, error) {
+	query := `
+SELECT
+	id,
+	task_id,
+	flag
+FROM task_flags
+WHERE
+	task_id = ?
+ORDER BY id ASC
+`
+	rows, err := s.db.Query(ctx, query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TaskFlagXXX, 0)
+	for rows.Next() {
+		var row TaskFlagXXX
+		if err := rows.Scan(&row.ID, &row.TaskID, &row.Flag); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *TaskInternal) AddFlag(ctx context.Context, taskID int, flag string) (int, error) {
+	query := `
+INSERT INTO task_flags (task_id, flag) VALUES (?, ?)
+`
+	return s.db.Insert(ctx, query, taskID, flag)
+}
+
+func (s *TaskInternal) DeleteFlag(ctx context.Context, taskID int, flagID int) error {
+	query := `
+DELETE FROM task_flags WHERE id = ? AND task_id = ?
+`
+	result, err := s.db.Exec(ctx, query, flagID, taskID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
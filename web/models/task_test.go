@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"testing"
+	"time"
+)
+
+func newTestTaskDB(t *testing.T) *TaskInternal {
+	t.Helper()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { dbSrv.Close(context.Background()) })
+	if _, err := dbSrv.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return NewTaskDB(dbSrv)
+}
+
+// addTestTask adds task started at startedAt with one flag
+func addTestTask(t *testing.T, s *TaskInternal, name string, startedAt time.Time, flag string) int {
+	t.Helper()
+	ctx := context.Background()
+	startedAt = startedAt.UTC()
+	taskID, err := s.AddTask(ctx, TaskXXX{Name: name, Category: "web", Difficult: "easy", StartedAt: &startedAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddFlag(ctx, taskID, flag); err != nil {
+		t.Fatal(err)
+	}
+	return taskID
+}
+
+func TestFlagsUpdateWorker(t *testing.T) {
+	saved := *config.Config
+	defer func() { *config.Config = saved }()
+	config.Config.FlagCachePollInterval = time.Minute
+
+	ctx := context.Background()
+	s := newTestTaskDB(t)
+	oldTaskID := addTestTask(t, s, "old", time.Now().Add(-time.Hour), "ctf{old}")
+	startAt := time.Now().Add(500 * time.Millisecond)
+	newTaskID := addTestTask(t, s, "new", startAt, "ctf{new}")
+
+	// first check loads flags and wakes up when next task starts instead of after poll interval
+	wait := s.updateWorker(ctx)
+	if wait > time.Until(startAt)+time.Millisecond || wait < flagsMinWait {
+		t.Fatalf("wait %s with next task starting in %s", wait, time.Until(startAt))
+	}
+	if taskID, err := s.GetByFlag(ctx, "ctf{old}"); err != nil || taskID != oldTaskID {
+		t.Fatalf("started task flag %d %v", taskID, err)
+	}
+	if _, err := s.GetByFlag(ctx, "ctf{new}"); err == nil {
+		t.Fatal("flag of not started task is in cache")
+	}
+	state := s.FlagsCacheState()
+	if state.NextStartAt == nil || !state.NextStartAt.Equal(startAt.UTC()) {
+		t.Fatalf("next start at %v, want %v", state.NextStartAt, startAt)
+	}
+
+	// flag added without version bump is not picked up, check time still moves
+	if _, err := s.AddFlag(ctx, oldTaskID, "ctf{old2}"); err != nil {
+		t.Fatal(err)
+	}
+	s.updateWorker(ctx)
+	if _, err := s.GetByFlag(ctx, "ctf{old2}"); err == nil {
+		t.Fatal("flags reloaded without version change")
+	}
+	if checked := s.FlagsCacheState(); !checked.CheckedAt.After(state.CheckedAt) || !checked.ReloadedAt.Equal(state.ReloadedAt) {
+		t.Fatalf("checked at %v reloaded at %v after check without change", checked.CheckedAt, checked.ReloadedAt)
+	}
+
+	if err := s.BumpFlagsVersion(ctx); err != nil {
+		t.Fatal(err)
+	}
+	s.updateWorker(ctx)
+	if _, err := s.GetByFlag(ctx, "ctf{old2}"); err != nil {
+		t.Fatalf("flags not reloaded after version bump: %v", err)
+	}
+	if version := s.FlagsCacheState().Version; version != state.Version+1 {
+		t.Fatalf("version %d, want %d", version, state.Version+1)
+	}
+
+	// task started, worker reloads flags without version bump
+	time.Sleep(time.Until(startAt) + 10*time.Millisecond)
+	wait = s.updateWorker(ctx)
+	if taskID, err := s.GetByFlag(ctx, "ctf{new}"); err != nil || taskID != newTaskID {
+		t.Fatalf("flag of started task %d %v", taskID, err)
+	}
+	if state := s.FlagsCacheState(); state.NextStartAt != nil {
+		t.Fatalf("next start at %v with all tasks started", state.NextStartAt)
+	}
+	if wait != config.Config.FlagCachePollInterval {
+		t.Fatalf("wait %s without tasks to start", wait)
+	}
+}
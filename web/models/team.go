@@ -4,49 +4,55 @@ import (
 	"context"
 	"ctfplatform/db"
 	"ctfplatform/rand"
+	"ctfplatform/storage"
+	"database/sql"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"strings"
 	"time"
 )
 
+// team states, only active team is ranked, see actions for what each state can do
+const (
+	TeamStateActive       = "active"
+	TeamStateHidden       = "hidden"
+	TeamStateBanned       = "banned"
+	TeamStateDisqualified = "disqualified"
+)
+
 type TeamXXX struct {
 	ID          int
 	Name        string
 	Email       string
 	Password    string
+	Active      bool
+	State       string
+	StateReason string
 	AvatarPath  string
 	Country     string
 	CreatedAt   time.Time
 	Affiliation string
 	Website     string
+	DeletedAt   *time.Time
+
+	SessionVersion int
 }
 
 func GenAvatarFilename() string {
 	return fmt.Sprintf("%s.png", rand.RandStringRunes(32))
 }
 
-func (t *TeamXXX) SetAvatar(ctx context.Context, db *TeamInternal, avatarPayload []byte) error {
+// SetAvatar stores payload under new name, old avatar should be deleted by caller after team is saved
+func (t *TeamXXX) SetAvatar(ctx context.Context, store storage.Storage, avatarPayload []byte) error {
 	if len(avatarPayload) == 0 {
 		t.AvatarPath = ""
 		return nil
 	}
-	oldAvatar := t.AvatarPath
 	newAvatar := GenAvatarFilename()
-	t.AvatarPath = newAvatar
-
-	if err := db.UpdateAvatar(ctx, *t, avatarPayload); err != nil {
-		t.AvatarPath = oldAvatar
+	if err := store.Put(ctx, t.ID, newAvatar, avatarPayload); err != nil {
 		return err
 	}
-	//if err := ioutil.WriteFile(filepath.Join(config.Config.AvatarPath, newAvatar), avatarPayload, 0644); err != nil {
-	//	return err
-	//}
-	//if len(t.AvatarPath) != 0 {
-	//	if err := os.Remove(filepath.Join(config.Config.AvatarPath, t.AvatarPath)); err != nil {
-	//		log.Log.WithError(err).Warningf("os.Remove avatar: %v", t.AvatarPath)
-	//	}
-	//}
+	t.AvatarPath = newAvatar
 	return nil
 }
 
@@ -85,6 +91,8 @@ SELECT
 	affiliation,
 	website
 FROM team
+WHERE
+	state = 'active'
 ORDER BY id ASC
 `
 	rows, err := s.db.Query(ctx, query)
@@ -111,17 +119,22 @@ SELECT
 	name,
 	email,
 	password,
+	active,
+	state,
+	state_reason,
 	created_at,
 	avatar,
 	country,
 	affiliation,
-	website
+	website,
+	deleted_at,
+	session_version
 FROM team
 WHERE
 	id = ?
 `
 	var out TeamXXX
-	err := s.db.QueryRow(ctx, query, id).Scan(&out.ID, &out.Name, &out.Email, &out.Password, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website)
+	err := s.db.QueryRow(ctx, query, id).Scan(&out.ID, &out.Name, &out.Email, &out.Password, &out.Active, &out.State, &out.StateReason, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website, &out.DeletedAt, &out.SessionVersion)
 	if err != nil {
 		return nil, err
 	}
@@ -154,11 +167,13 @@ SELECT
 	name,
 	email,
 	password,
+	active,
 	created_at,
 	avatar,
 	country,
 	affiliation,
-	website
+	website,
+	session_version
 FROM team
 WHERE
 	id IN (%s)
@@ -172,7 +187,7 @@ WHERE
 	result := make(map[int]*TeamXXX)
 	for rows.Next() {
 		var out TeamXXX
-		if err := rows.Scan(&out.ID, &out.Name, &out.Email, &out.Password, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website); err != nil {
+		if err := rows.Scan(&out.ID, &out.Name, &out.Email, &out.Password, &out.Active, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website, &out.SessionVersion); err != nil {
 			return nil, err
 		}
 		result[out.ID] = &out
@@ -187,59 +202,134 @@ SELECT
 	name,
 	email,
 	password,
+	active,
+	state,
 	created_at,
 	avatar,
 	country,
 	affiliation,
-	website
+	website,
+	session_version
 FROM team
 WHERE
 	email = ? OR name = ?
 LIMIT 1
 `
 	var out TeamXXX
-	err := s.db.QueryRow(ctx, query, email, email).Scan(&out.ID, &out.Name, &out.Email, &out.Password, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website)
+	err := s.db.QueryRow(ctx, query, email, email).Scan(&out.ID, &out.Name, &out.Email, &out.Password, &out.Active, &out.State, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website, &out.SessionVersion)
 	if err != nil {
 		return nil, err
 	}
 	return &out, nil
 }
 
-func (s *TeamInternal) AddTeam(ctx context.Context, team TeamXXX) error {
+func (s *TeamInternal) AddTeam(ctx context.Context, team TeamXXX) (int, error) {
+	query := `
+INSERT INTO team (name, email, password, created_at, active, avatar, country) VALUES (?, ?, ?, NOW(), ?, ?, ?)
+`
+	return s.db.Insert(ctx, query, team.Name, team.Email, team.Password, team.Active, team.AvatarPath, team.Country)
+}
+
+func (s *TeamInternal) SetActive(ctx context.Context, teamID int, active bool) error {
+	query := `
+UPDATE team SET active = ? WHERE id = ?
+`
+	_, err := s.db.Exec(ctx, query, active, teamID)
+	return err
+}
+
+// SetState should be logged with AddModeration
+func (s *TeamInternal) SetState(ctx context.Context, teamID int, state string, reason string) error {
 	query := `
-INSERT INTO team (id, name, email, password, created_at, active, avatar, country) VALUES (NULL, ?, ?, ?, NOW(), 1, ?, ?)
+UPDATE team SET state = ?, state_reason = ? WHERE id = ?
 `
-	_, err := s.db.Exec(ctx, query, team.Name, team.Email, team.Password, team.AvatarPath, team.Country)
+	_, err := s.db.Exec(ctx, query, state, reason, teamID)
 	return err
 }
 
 func (s *TeamInternal) UpdateTeam(ctx context.Context, team TeamXXX) error {
 	query := `
-UPDATE team SET name = ?, password = ?, avatar = ?, country = ?, affiliation = ?, website = ? WHERE id = ?
+UPDATE team SET name = ?, email = ?, password = ?, avatar = ?, country = ?, affiliation = ?, website = ? WHERE id = ?
 `
-	_, err := s.db.Exec(ctx, query, team.Name, team.Password, team.AvatarPath, team.Country, team.Affiliation, team.Website, team.ID)
+	_, err := s.db.Exec(ctx, query, team.Name, team.Email, team.Password, team.AvatarPath, team.Country, team.Affiliation, team.Website, team.ID)
 	return err
 }
 
-// TODO: other model
-func (s *TeamInternal) UpdateAvatar(ctx context.Context, team TeamXXX, avatarPayload []byte) error {
+// SetAvatarPath changes only avatar, used when avatar is approved outside of team settings request
+func (s *TeamInternal) SetAvatarPath(ctx context.Context, teamID int, avatarPath string) error {
 	query := `
-INSERT INTO team_avatar (id, team_id, avatar_path, avatar) VALUES (NULL, ?, ?, ?) 
-ON DUPLICATE KEY 
-UPDATE avatar_path=VALUES(avatar_path), avatar=VALUES(avatar)
+UPDATE team SET avatar = ? WHERE id = ?
 `
-	_, err := s.db.Exec(ctx, query, team.ID, team.AvatarPath, avatarPayload)
+	_, err := s.db.Exec(ctx, query, avatarPath, teamID)
 	return err
 }
 
-func (s *TeamInternal) GetAvatar(ctx context.Context, avatarPath string) ([]byte, error) {
+// AdminAll returns also private team data
+func (s *TeamInternal) AdminAll(ctx context.Context) ([]*TeamXXX, error) {
 	query := `
-SELECT avatar FROM team_avatar WHERE avatar_path = ?
+SELECT
+	id,
+	name,
+	email,
+	active,
+	state,
+	state_reason,
+	created_at,
+	avatar,
+	country,
+	affiliation,
+	website,
+	deleted_at
+FROM team
+ORDER BY id ASC
 `
-	var out []byte
-	err := s.db.QueryRow(ctx, query, avatarPath).Scan(&out)
+	rows, err := s.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	return out, nil
+	defer rows.Close()
+
+	result := make([]*TeamXXX, 0)
+	for rows.Next() {
+		var out TeamXXX
+		if err := rows.Scan(&out.ID, &out.Name, &out.Email, &out.Active, &out.State, &out.StateReason, &out.CreatedAt, &out.AvatarPath, &out.Country, &out.Affiliation, &out.Website, &out.DeletedAt); err != nil {
+			return nil, err
+		}
+		result = append(result, &out)
+	}
+	return result, nil
+}
+
+// DeleteTeam removes team with all its data, team with solves can not be removed (audit foreign key)
+func (s *TeamInternal) DeleteTeam(ctx context.Context, teamID int) error {
+	return s.db.Tx(ctx, func(ctx context.Context) error {
+		// team_moderation is not deleted, team with moderation history can not be deleted
+		for _, query := range []string{
+			`DELETE FROM team_token WHERE team_id = ?`,
+			`DELETE FROM team_session WHERE team_id = ?`,
+			`DELETE FROM password_reset WHERE team_id = ?`,
+			`DELETE FROM team_avatar WHERE team_id = ?`,
+			`DELETE FROM avatar_moderation WHERE team_id = ?`,
+		} {
+			if _, err := s.db.Exec(ctx, query, teamID); err != nil {
+				return err
+			}
+		}
+
+		query := `
+DELETE FROM team WHERE id = ?
+`
+		result, err := s.db.Exec(ctx, query, teamID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected != 1 {
+			return sql.ErrNoRows
+		}
+		return nil
+	})
 }
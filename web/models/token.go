@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+const (
+	TokenScopeRead   = "read"
+	TokenScopeSubmit = "submit"
+)
+
+type TeamTokenXXX struct {
+	ID         int
+	TeamID     int
+	Name       string
+	Scope      string
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+}
+
+// HasScope submit scope includes read scope
+func (t *TeamTokenXXX) HasScope(scope string) bool {
+	if scope == TokenScopeRead {
+		return t.Scope == TokenScopeRead || t.Scope == TokenScopeSubmit
+	}
+	return t.Scope == scope
+}
+
+func (s *TeamInternal) AddToken(ctx context.Context, teamID int, name string, scope string, tokenHash string) (int, error) {
+	query := `
+INSERT INTO team_token (team_id, name, scope, token_hash, created_at) VALUES (?, ?, ?, ?, NOW())
+`
+	return s.db.Insert(ctx, query, teamID, name, scope, tokenHash)
+}
+
+func (s *TeamInternal) GetTokenByHash(ctx context.Context, tokenHash string) (*TeamTokenXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	name,
+	scope,
+	created_at,
+	last_used_at
+FROM team_token
+WHERE
+	token_hash = ?
+	AND revoked_at IS NULL
+`
+	var out TeamTokenXXX
+	err := s.db.QueryRow(ctx, query, tokenHash).Scan(&out.ID, &out.TeamID, &out.Name, &out.Scope, &out.CreatedAt, &out.LastUsedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *TeamInternal) GetTokens(ctx context.Context, teamID int) ([]*TeamTokenXXX, error) {
+	query := `
+SELECT
+	id,
+	team_id,
+	name,
+	scope,
+	created_at,
+	last_used_at
+FROM team_token
+WHERE
+	team_id = ?
+	AND revoked_at IS NULL
+ORDER BY id ASC
+`
+	rows, err := s.db.Query(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]*TeamTokenXXX, 0)
+	for rows.Next() {
+		var row TeamTokenXXX
+		if err := rows.Scan(&row.ID, &row.TeamID, &row.Name, &row.Scope, &row.CreatedAt, &row.LastUsedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, &row)
+	}
+	return out, nil
+}
+
+func (s *TeamInternal) TouchToken(ctx context.Context, tokenID int) error {
+	query := `
+UPDATE team_token SET last_used_at = NOW() WHERE id = ?
+`
+	_, err := s.db.Exec(ctx, query, tokenID)
+	return err
+}
+
+func (s *TeamInternal) RevokeToken(ctx context.Context, teamID int, tokenID int) error {
+	query := `
+UPDATE team_token SET revoked_at = NOW() WHERE id = ? AND team_id = ? AND revoked_at IS NULL
+`
+	result, err := s.db.Exec(ctx, query, tokenID, teamID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected != 1 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package session
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// v2 session format: "v2.<key id>.<base64(nonce + AES-256-GCM ciphertext)>"
+const formatV2 = "v2"
+
+var ErrSessionExpired = errors.New("session expired")
+
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// ParseKeys parses keys in "<id>:<base64 32 bytes key>" format
+func ParseKeys(specs []string) ([]Key, error) {
+	keys := make([]Key, 0, len(specs))
+	for _, spec := range specs {
+		arrSplit := strings.SplitN(spec, ":", 2)
+		if len(arrSplit) != 2 {
+			return nil, fmt.Errorf("session key %q: should be <id>:<base64 key>", spec)
+		}
+		for _, key := range keys {
+			if key.ID == arrSplit[0] {
+				return nil, fmt.Errorf("session key %q: duplicated id", key.ID)
+			}
+		}
+		secret, err := base64.StdEncoding.DecodeString(arrSplit[1])
+		if err != nil {
+			return nil, fmt.Errorf("session key %q: %w", arrSplit[0], err)
+		}
+		keys = append(keys, Key{ID: arrSplit[0], Secret: secret})
+	}
+	return keys, nil
+}
+
+// DeriveKey makes v2 key from old AES/HMAC secrets, so deployments without configured keys still work
+func DeriveKey(id string, aesKey, hmacKey []byte) Key {
+	h := hmac.New(sha256.New, hmacKey)
+	h.Write([]byte("session v2 key"))
+	h.Write(aesKey)
+	return Key{ID: id, Secret: h.Sum(nil)}
+}
+
+type legacyKey struct {
+	aesKey  []byte
+	hmacKey []byte
+}
+
+// Keyring encrypts sessions with the first (primary) key and decrypts with any of them,
+// so keys can be rotated by adding new key in front and removing the old one after sessions are refreshed.
+// Keys with the same id are tried in order, derived key keeps its id when secrets it comes from are rotated.
+type Keyring struct {
+	primary     Key
+	keys        map[string][]cipher.AEAD
+	legacy      []legacyKey
+	legacyUntil time.Time
+}
+
+func NewKeyring(keys []Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one session key is required")
+	}
+
+	k := &Keyring{
+		primary: keys[0],
+		keys:    make(map[string][]cipher.AEAD, len(keys)),
+	}
+	for _, key := range keys {
+		if len(key.ID) == 0 || strings.Contains(key.ID, ".") {
+			return nil, fmt.Errorf("session key %q: invalid id", key.ID)
+		}
+		if len(key.Secret) != 32 {
+			return nil, fmt.Errorf("session key %q: should have 32 bytes", key.ID)
+		}
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[key.ID] = append(k.keys[key.ID], aead)
+	}
+	return k, nil
+}
+
+// AddLegacy accepts old (v1) sessions encrypted with given keys until given time.
+// v1 sessions have no expiry, so until is also expiry of every legacy session.
+func (k *Keyring) AddLegacy(aesKey, hmacKey []byte, until time.Time) {
+	k.legacy = append(k.legacy, legacyKey{aesKey: aesKey, hmacKey: hmacKey})
+	k.legacyUntil = until
+}
+
+type payloadV2 struct {
+	ExpiresAt int64           `json:"exp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (k *Keyring) Marshal(in interface{}, ttl time.Duration) ([]byte, error) {
+	return k.MarshalUntil(in, time.Now().Add(ttl))
+}
+
+// MarshalUntil is used to issue stale session again, it keeps original expiry
+func (k *Keyring) MarshalUntil(in interface{}, expiresAt time.Time) ([]byte, error) {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(payloadV2{
+		ExpiresAt: expiresAt.Unix(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	aead := k.keys[k.primary.ID][0]
+	header := formatV2 + "." + k.primary.ID
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nonce, nonce, plaintext, []byte(header))
+
+	return []byte(header + "." + base64.RawURLEncoding.EncodeToString(ciphertext)), nil
+}
+
+// Unmarshal decodes session in any supported format, stale is true when
+// session should be issued again with the primary key (old format or old key),
+// expiresAt should be kept then, see MarshalUntil.
+func (k *Keyring) Unmarshal(in []byte, out interface{}) (expiresAt time.Time, stale bool, err error) {
+	if !bytes.HasPrefix(in, []byte(formatV2+".")) {
+		return k.legacyUntil, true, k.unmarshalLegacy(in, out)
+	}
+
+	arrSplit := bytes.SplitN(in, []byte("."), 3)
+	if len(arrSplit) != 3 {
+		return time.Time{}, false, errors.New("should have three parts")
+	}
+	keyID := string(arrSplit[1])
+	aeads, exists := k.keys[keyID]
+	if !exists {
+		return time.Time{}, false, fmt.Errorf("unknown session key: %q", keyID)
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(string(arrSplit[2]))
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	// every key is AES-GCM with the same nonce size
+	if len(ciphertext) < aeads[0].NonceSize() {
+		return time.Time{}, false, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := ciphertext[:aeads[0].NonceSize()], ciphertext[aeads[0].NonceSize():]
+	header := formatV2 + "." + keyID
+	var plaintext []byte
+	used := 0
+	for i, aead := range aeads {
+		if plaintext, err = aead.Open(nil, nonce, ciphertext, []byte(header)); err == nil {
+			used = i
+			break
+		}
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	var payload payloadV2
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return time.Time{}, false, err
+	}
+	if time.Now().Unix() > payload.ExpiresAt {
+		return time.Time{}, false, ErrSessionExpired
+	}
+	if err := json.Unmarshal(payload.Data, out); err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Unix(payload.ExpiresAt, 0), keyID != k.primary.ID || used != 0, nil
+}
+
+func (k *Keyring) unmarshalLegacy(in []byte, out interface{}) error {
+	if len(k.legacy) == 0 {
+		return errors.New("legacy sessions not accepted")
+	}
+	if time.Now().After(k.legacyUntil) {
+		return ErrSessionExpired
+	}
+	var err error
+	for _, key := range k.legacy {
+		if err = UnmarshalSession(key.aesKey, key.hmacKey, in, out); err == nil {
+			return nil
+		}
+	}
+	return err
+}
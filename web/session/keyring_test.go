@@ -0,0 +1,152 @@
+package session
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+type testSession struct {
+	TeamID int `json:"team_id"`
+}
+
+func testKey(id string, b byte) Key {
+	secret := make([]byte, 32)
+	for i := range secret {
+		secret[i] = b
+	}
+	return Key{ID: id, Secret: secret}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKeyring, err := NewKeyring([]Key{testKey("old", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	encoded, err := oldKeyring.MarshalUntil(testSession{TeamID: 7}, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := NewKeyring([]Key{testKey("new", 2), testKey("old", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out testSession
+	gotExpiresAt, stale, err := keyring.Unmarshal(encoded, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale || out.TeamID != 7 || !gotExpiresAt.Equal(expiresAt) {
+		t.Fatalf("stale %v, team %d, expires %s", stale, out.TeamID, gotExpiresAt)
+	}
+
+	// issued again with primary key, expiry is kept
+	encoded, err = keyring.MarshalUntil(out, gotExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotExpiresAt, stale, err = keyring.Unmarshal(encoded, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale || !gotExpiresAt.Equal(expiresAt) {
+		t.Fatalf("stale %v, expires %s", stale, gotExpiresAt)
+	}
+
+	removed, err := NewKeyring([]Key{testKey("new", 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := removed.Unmarshal([]byte("v2.old.AAAA"), &out); err == nil {
+		t.Fatal("session with removed key accepted")
+	}
+}
+
+func TestKeyringExpired(t *testing.T) {
+	keyring, err := NewKeyring([]Key{testKey("k", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := keyring.Marshal(testSession{TeamID: 1}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out testSession
+	if _, _, err := keyring.Unmarshal(encoded, &out); err != ErrSessionExpired {
+		t.Fatalf("expired session: %v", err)
+	}
+}
+
+func TestKeyringLegacy(t *testing.T) {
+	aesKey, hmacKey := make([]byte, 32), make([]byte, 32)
+	encoded, err := MarshalSession(aesKey, hmacKey, testSession{TeamID: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := NewKeyring([]Key{testKey("k", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out testSession
+	if _, _, err := keyring.Unmarshal(encoded, &out); err == nil {
+		t.Fatal("legacy session accepted without legacy keys")
+	}
+
+	until := time.Now().Add(time.Hour)
+	keyring.AddLegacy(aesKey, hmacKey, until)
+	expiresAt, stale, err := keyring.Unmarshal(encoded, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale || out.TeamID != 3 || !expiresAt.Equal(until) {
+		t.Fatalf("stale %v, team %d, expires %s", stale, out.TeamID, expiresAt)
+	}
+
+	keyring.AddLegacy(aesKey, hmacKey, time.Now().Add(-time.Hour))
+	if _, _, err := keyring.Unmarshal(encoded, &out); err != ErrSessionExpired {
+		t.Fatalf("legacy session after cutoff: %v", err)
+	}
+}
+
+func TestKeyringSameID(t *testing.T) {
+	oldKeyring, err := NewKeyring([]Key{testKey("default", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := oldKeyring.Marshal(testSession{TeamID: 5}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// key derived from rotated secrets keeps its id
+	keyring, err := NewKeyring([]Key{testKey("default", 2), testKey("default", 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out testSession
+	_, stale, err := keyring.Unmarshal(encoded, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale || out.TeamID != 5 {
+		t.Fatalf("stale %v, team %d", stale, out.TeamID)
+	}
+
+	encoded, err = keyring.Marshal(out, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, stale, err := keyring.Unmarshal(encoded, &out); err != nil || stale {
+		t.Fatalf("stale %v, err %v", stale, err)
+	}
+	if _, _, err := oldKeyring.Unmarshal(encoded, &out); err == nil {
+		t.Fatal("session issued again still uses old key")
+	}
+
+	if _, err := ParseKeys([]string{"a:" + base64.StdEncoding.EncodeToString(make([]byte, 32)), "a:" + base64.StdEncoding.EncodeToString(make([]byte, 32))}); err == nil {
+		t.Fatal("duplicated id in SESSION_KEYS accepted")
+	}
+}
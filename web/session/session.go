@@ -19,11 +19,23 @@ func PKCS5Padding(ciphertext []byte, blockSize int) []byte {
 	return append(ciphertext, padtext...)
 }
 
-func PKCS5Trimming(encrypt []byte) []byte {
-	padding := encrypt[len(encrypt)-1]
-	return encrypt[:len(encrypt)-int(padding)]
+func PKCS5Trimming(encrypt []byte, blockSize int) ([]byte, error) {
+	if len(encrypt) == 0 || len(encrypt)%blockSize != 0 {
+		return nil, errors.New("invalid padding size")
+	}
+	padding := int(encrypt[len(encrypt)-1])
+	if padding == 0 || padding > blockSize {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range encrypt[len(encrypt)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return encrypt[:len(encrypt)-padding], nil
 }
 
+// MarshalSession is old (v1) session format AES-CBC + HMAC, new sessions use Keyring
 func MarshalSession(key1, key2 []byte, in interface{}) ([]byte, error) {
 	plaintext, err := json.Marshal(in)
 	if err != nil {
@@ -95,6 +107,9 @@ func UnmarshalSession(key1, key2 []byte, in []byte, out interface{}) error {
 	}
 
 	block, err := aes.NewCipher(key1)
+	if err != nil {
+		return err
+	}
 	if len(ciphertext) < aes.BlockSize {
 		return errors.New("ciphertext too short")
 	}
@@ -108,6 +123,9 @@ func UnmarshalSession(key1, key2 []byte, in []byte, out interface{}) error {
 	mode := cipher.NewCBCDecrypter(block, iv)
 	mode.CryptBlocks(ciphertext, ciphertext)
 
-	plaintext := PKCS5Trimming(ciphertext)
+	plaintext, err := PKCS5Trimming(ciphertext, aes.BlockSize)
+	if err != nil {
+		return err
+	}
 	return json.Unmarshal(plaintext, out)
 }
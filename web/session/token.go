@@ -0,0 +1,59 @@
+package session
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// MarshalToken signs (without encrypting) in, output is url safe so it can be sent in links
+func MarshalToken(key []byte, in interface{}) ([]byte, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	h := hmac.New(sha256.New, key)
+	_, err = h.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+	hashSum := h.Sum(nil)
+
+	payloadBase64 := base64.RawURLEncoding.EncodeToString(payload)
+	hashSumBase64 := base64.RawURLEncoding.EncodeToString(hashSum)
+
+	return []byte(payloadBase64 + "." + hashSumBase64), nil
+}
+
+func UnmarshalToken(key []byte, in []byte, out interface{}) error {
+	arrSplit := bytes.SplitN(in, []byte("."), 2)
+	if len(arrSplit) != 2 {
+		return errors.New("should have two dots")
+	}
+	payloadBase64, hashSumBase64 := string(arrSplit[0]), string(arrSplit[1])
+
+	hashSum, err := base64.RawURLEncoding.DecodeString(hashSumBase64)
+	if err != nil {
+		return err
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(payloadBase64)
+	if err != nil {
+		return err
+	}
+
+	h := hmac.New(sha256.New, key)
+	_, err = h.Write(payload)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(hashSum, h.Sum(nil)) {
+		return errors.New("hmac not same")
+	}
+
+	return json.Unmarshal(payload, out)
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"ctfplatform/db"
+	"database/sql"
+)
+
+// DBStorage keeps avatars in team_avatar table, row per object so new avatar does not replace old one before team points to it
+type DBStorage struct {
+	db *db.DatabaseInternal
+}
+
+func NewDBStorage(db *db.DatabaseInternal) *DBStorage {
+	return &DBStorage{
+		db: db,
+	}
+}
+
+func (s *DBStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	query := `
+SELECT avatar FROM team_avatar WHERE avatar_path = ?
+`
+	var out []byte
+	err := s.db.QueryRow(ctx, query, key).Scan(&out)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *DBStorage) Put(ctx context.Context, teamID int, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	query := `
+INSERT INTO team_avatar (team_id, avatar_path, avatar) VALUES (?, ?, ?)
+` + s.db.Dialect().OnConflictUpdate([]string{"avatar_path"}, []string{"team_id", "avatar"})
+	_, err := s.db.Exec(ctx, query, teamID, key, data)
+	return err
+}
+
+func (s *DBStorage) Delete(ctx context.Context, key string) error {
+	query := `
+DELETE FROM team_avatar WHERE avatar_path = ?
+`
+	_, err := s.db.Exec(ctx, query, key)
+	return err
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"ctfplatform/db"
+	"testing"
+)
+
+func TestDBStorage(t *testing.T) {
+	ctx := context.Background()
+	dbSrv, err := db.NewDB("sqlite://:memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbSrv.Close(ctx)
+	if _, err := dbSrv.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	teamID, err := dbSrv.Insert(ctx, `INSERT INTO team (name, email, password, avatar, country) VALUES ('team', 'team@example.com', '', '', 'PL')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewDBStorage(dbSrv)
+
+	// new avatar of team does not replace old one, it is deleted after team points to new one
+	if err := s.Put(ctx, teamID, "old.png", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(ctx, teamID, "new.png", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"old.png": "old", "new.png": "new"} {
+		if data, err := s.Get(ctx, key); err != nil || string(data) != want {
+			t.Fatalf("%s is %q: %v", key, data, err)
+		}
+	}
+
+	// the same key is overwritten like file or object, avatar migration can be run again
+	if err := s.Put(ctx, teamID, "new.png", []byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := s.Get(ctx, "new.png"); err != nil || string(data) != "again" {
+		t.Fatalf("overwritten key is %q: %v", data, err)
+	}
+
+	if err := s.Delete(ctx, "old.png"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get(ctx, "old.png"); err != ErrNotFound {
+		t.Fatalf("deleted key: %v", err)
+	}
+	if err := s.Put(ctx, teamID, "../x.png", []byte("x")); err == nil {
+		t.Fatal("invalid key stored")
+	}
+}
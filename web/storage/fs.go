@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FSStorage keeps avatars as files in one directory, nginx can serve it directly
+type FSStorage struct {
+	root string
+}
+
+func NewFSStorage(root string) (*FSStorage, error) {
+	if len(root) == 0 {
+		return nil, errors.New("fs storage requires path")
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("fs storage: %w", err)
+	}
+	return &FSStorage{
+		root: root,
+	}, nil
+}
+
+func (s *FSStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, ErrNotFound
+	}
+	out, err := os.ReadFile(filepath.Join(s.root, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return out, err
+}
+
+// Put writes temp file and renames it, reader never sees half written avatar
+func (s *FSStorage) Put(ctx context.Context, teamID int, key string, data []byte) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(s.root, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.root, key))
+}
+
+func (s *FSStorage) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	err := os.Remove(filepath.Join(s.root, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
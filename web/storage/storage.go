@@ -0,0 +1,61 @@
+// Package storage keeps avatar images, key is file name stored in team.avatar
+package storage
+
+import (
+	"context"
+	"ctfplatform/config"
+	"ctfplatform/db"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrNotFound = errors.New("object not found")
+var ErrInvalidKey = errors.New("invalid object key")
+
+const (
+	BackendDB = "db"
+	BackendFS = "fs"
+	BackendS3 = "s3"
+)
+
+type Storage interface {
+	// Get returns ErrNotFound when key does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put overwrites existing key, teamID is used only by db backend to remove rows with team
+	Put(ctx context.Context, teamID int, key string, data []byte) error
+	// Delete does not fail when key does not exist
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey keys are generated file names, anything else could escape fs root or bucket prefix
+func checkKey(key string) error {
+	if len(key) == 0 || strings.ContainsAny(key, "/\\") || strings.HasPrefix(key, ".") {
+		return fmt.Errorf("%w: %q", ErrInvalidKey, key)
+	}
+	return nil
+}
+
+// NewStorageFromConfig backend is passed separately, migration opens two backends at once
+func NewStorageFromConfig(backend string, dbSrv *db.DatabaseInternal) (Storage, error) {
+	return NewStorage(backend, dbSrv, config.Config.AvatarFsPath, S3Config{
+		Endpoint:  config.Config.AvatarS3Endpoint,
+		Bucket:    config.Config.AvatarS3Bucket,
+		Region:    config.Config.AvatarS3Region,
+		AccessKey: config.Config.AvatarS3AccessKey,
+		SecretKey: config.Config.AvatarS3SecretKey,
+		PathStyle: config.Config.AvatarS3PathStyle,
+	})
+}
+
+func NewStorage(backend string, dbSrv *db.DatabaseInternal, fsPath string, s3 S3Config) (Storage, error) {
+	switch backend {
+	case BackendDB:
+		return NewDBStorage(dbSrv), nil
+	case BackendFS:
+		return NewFSStorage(fsPath)
+	case BackendS3:
+		return NewS3Storage(s3)
+	}
+	return nil, fmt.Errorf("unknown avatar storage: %s", backend)
+}